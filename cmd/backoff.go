@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"time"
+
+	"queuectl/internal/backoff"
+	"queuectl/internal/store"
+)
+
+// resolveBackoffPolicy returns the policy configured for jobs in queue.
+// Settings stored on a job are applied on top by the worker.
+func resolveBackoffPolicy(st store.Store, queue string) backoff.Policy {
+	strategy, _ := resolveConfigValue(st, queue, "backoff-strategy")
+	base, _ := resolveConfig(st, queue, "backoff-base")
+	maxDelay, _ := resolveConfig(st, queue, "backoff-max")
+
+	return backoff.Policy{
+		Strategy: strategy,
+		Base:     time.Duration(base) * time.Second,
+		Max:      time.Duration(maxDelay) * time.Second,
+	}
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"queuectl/pkg/client"
+
+	"github.com/spf13/cobra"
+)
+
+var cancelState string
+var cancelQueue string
+var cancelMatch string
+var cancelReason string
+var cancelBy string
+
+var cancelCmd = &cobra.Command{
+	Use:   "cancel [jobId...]",
+	Short: "Cancel waiting jobs and kill running ones",
+	Long: `Cancel jobs by ID, by filter, or both.
+
+Pending and failed (waiting to retry) jobs move to the "cancelled" state
+right away. For processing jobs the owning worker is asked to terminate
+the command: it gets SIGTERM, then is killed if it hasn't exited a few
+seconds later. Finished jobs are left alone.
+
+Examples:
+  queuectl cancel k3j4h5g6 p9o8i7u6
+  queuectl cancel --state pending --queue emails --match 'newsletter'`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		if len(args) == 0 && cancelState == "" && cancelQueue == "" && cancelMatch == "" {
+			fmt.Println("❌ Error: give job IDs or at least one of --state, --queue, --match")
+			return
+		}
+
+		states := []string{"pending", "failed", "processing"}
+		if cancelState != "" {
+			if cancelState != "pending" && cancelState != "failed" && cancelState != "processing" {
+				fmt.Println("❌ Error: --state must be pending, failed or processing")
+				return
+			}
+			states = []string{cancelState}
+		}
+
+		var match *regexp.Regexp
+		if cancelMatch != "" {
+			var err error
+			match, err = regexp.Compile(cancelMatch)
+			if err != nil {
+				fmt.Println("❌ Error: invalid --match:", err)
+				return
+			}
+		}
+
+		c, err := openClient()
+		if err != nil {
+			fmt.Println("DB error:", err)
+			return
+		}
+		defer c.Close()
+		ctx := cmd.Context()
+
+		var targets []client.Job
+		if len(args) > 0 {
+			for _, id := range args {
+				job, err := c.Get(ctx, id)
+				if errors.Is(err, client.ErrNotFound) {
+					fmt.Println("No such job:", id)
+					continue
+				}
+				if err != nil {
+					fmt.Println("Query error:", err)
+					return
+				}
+				if job.State != "pending" && job.State != "failed" && job.State != "processing" {
+					fmt.Printf("Job %s is %s, nothing to cancel\n", id, job.State)
+					continue
+				}
+				if !slices.Contains(states, job.State) ||
+					(cancelQueue != "" && job.Queue != cancelQueue) ||
+					(match != nil && !match.MatchString(job.Command)) {
+					continue
+				}
+				targets = append(targets, job)
+			}
+		} else {
+			for _, state := range states {
+				jobs, _, err := c.List(ctx, client.ListOptions{State: state, Queue: cancelQueue, Match: cancelMatch})
+				if err != nil {
+					fmt.Println("Query error:", err)
+					return
+				}
+				targets = append(targets, jobs...)
+			}
+		}
+
+		opts := client.CancelOptions{By: cancelBy, Reason: cancelReason}
+		cancelled, killing := 0, 0
+		for _, target := range targets {
+			job, err := c.Cancel(ctx, target.Id, opts)
+
+			// Jobs that finished or were already being cancelled since
+			// they were looked up are left alone
+			var stateErr *client.StateError
+			if errors.As(err, &stateErr) || errors.Is(err, client.ErrCancelRequested) {
+				continue
+			}
+			if err != nil {
+				fmt.Printf("Error cancelling job %s: %v\n", target.Id, err)
+				continue
+			}
+			if job.State == "cancelled" {
+				cancelled++
+			} else {
+				killing++
+			}
+		}
+
+		if cancelled == 0 && killing == 0 {
+			fmt.Println("No jobs cancelled.")
+			return
+		}
+		if cancelled > 0 {
+			fmt.Printf("🚫 Cancelled %d waiting job(s)\n", cancelled)
+		}
+		if killing > 0 {
+			fmt.Printf("⏳ Asked workers to kill %d running job(s); they move to cancelled once stopped\n", killing)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cancelCmd)
+	cancelCmd.Flags().StringVarP(&cancelState, "state", "s", "", "Only cancel jobs in this state: pending, failed or processing")
+	cancelCmd.Flags().StringVarP(&cancelQueue, "queue", "q", "", "Only cancel jobs in this queue")
+	cancelCmd.Flags().StringVarP(&cancelMatch, "match", "m", "", "Only cancel jobs whose command matches this regular expression")
+	cancelCmd.Flags().StringVarP(&cancelReason, "reason", "r", "", "Why the jobs are cancelled (shown by list)")
+	cancelCmd.Flags().StringVar(&cancelBy, "by", "", "Who cancelled the jobs (default: user@host)")
+}
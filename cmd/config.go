@@ -1,149 +1,266 @@
 package cmd
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 
+	"queuectl/internal/store"
+	"queuectl/pkg/client"
+
 	"github.com/spf13/cobra"
-	_ "modernc.org/sqlite"
 )
 
+var configQueue string
+
+// resolveConfigValue returns the value of key for jobs in queue and where
+// it came from: "queue", "global" or "default". An empty queue skips the
+// queue overrides. Settings stored on the job itself (--max-retries,
+// --timeout, --backoff...) win over all of these and are checked by the
+// caller.
+func resolveConfigValue(st store.Store, queue, key string) (string, string) {
+	cfg, _ := newClient(st).GetConfig(context.Background(), queue, key)
+	return cfg.Value, cfg.Source
+}
+
+// resolveConfig is resolveConfigValue for numeric keys.
+func resolveConfig(st store.Store, queue, key string) (int, string) {
+	cfg, _ := newClient(st).GetConfig(context.Background(), queue, key)
+	if _, err := strconv.Atoi(cfg.Value); err != nil {
+		cfg.Source = "default"
+	}
+	return cfg.Int(), cfg.Source
+}
+
 var configCmd = &cobra.Command{
 	Use:   "config",
 	Short: "Manage queuectl configuration",
-	Long:  "Configure retry behavior and backoff settings for the queue system",
+	Long: `Configure retry behavior and backoff settings for the queue system.
+
+Settings are resolved per job in this order: the job's own value
+(--max-retries, --timeout, --backoff, --retry-on...), then the job's queue (config set --queue),
+then the global value (config set), then the built-in default.`,
 }
 
 var configSetCmd = &cobra.Command{
-	Use:   "set <key> <value>",
+	Use:   "set [--queue <name>] <key> <value>",
 	Short: "Set a configuration value",
 	Long: `Set configuration values for the queue system.
 
 Available keys:
-  max-retries   - Maximum number of retry attempts before job moves to DLQ (default: 3)
-  backoff-base  - Base multiplier for exponential backoff in seconds (default: 2)
-                  Retry delays: backoff-base^attempt (e.g., 2s, 4s, 8s, 16s...)`,
+  max-retries   - Maximum number of retry attempts before job moves to DLQ, for
+                  jobs enqueued without --max-retries (default: 3)
+  backoff-strategy - How retry delays grow (default: exponential):
+                  fixed, linear, exponential, full-jitter or decorrelated-jitter
+  backoff-base  - First retry delay in seconds (default: 2)
+                  Exponential delays: 2s, 4s, 8s, 16s...
+  backoff-max   - Longest retry delay in seconds (default: 3600)
+  retry-on      - Comma-separated exit codes that are retried; any other
+                  code sends the job to the DLQ. Empty = retry any failure
+  fail-fast-on  - Comma-separated exit codes that send the job straight to
+                  the DLQ without retrying (default: none)
+  reschedule-exit-code - Exit code that means "run me again later": the job
+                  waits one backoff delay without using up an attempt; 0 = off
+  lease-timeout - Seconds a worker may go without a heartbeat before its job
+                  is handed back to the queue (default: 30)
+  job-timeout   - Seconds a job may run before it is killed, for jobs enqueued
+                  without --timeout; 0 disables (default: 0)
+  output-limit  - Bytes of stdout and of stderr kept per job attempt; older
+                  output is dropped first (default: 65536)
+  priority-aging - Seconds a ready job must wait to gain one point of priority,
+                  so low-priority jobs can't starve; 0 = strict priority (default: 0)
+
+With --queue, the value only applies to jobs in that queue. all keys
+except lease-timeout and priority-aging can be set per queue.
+max-retries is fixed when a job is enqueued; the others are read by the
+worker each time it runs a job.`,
 	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
 
 		key := args[0]
 		value := args[1]
 
-		allowedKeys := map[string]bool{
-			"max-retries":  true,
-			"backoff-base": true,
-		}
-
-		if !allowedKeys[key] {
+		value, err := client.ValidateConfig(configQueue, key, value)
+		if errors.Is(err, client.ErrUnknownConfigKey) {
 			fmt.Println("❌ Invalid key. Allowed keys:")
 			fmt.Println("  max-retries   - Maximum retry attempts (e.g., 3)")
-			fmt.Println("  backoff-base  - Exponential backoff base in seconds (e.g., 2)")
+			fmt.Println("  backoff-strategy - fixed, linear, exponential, full-jitter or decorrelated-jitter")
+			fmt.Println("  backoff-base  - First retry delay in seconds (e.g., 2)")
+			fmt.Println("  backoff-max   - Longest retry delay in seconds (e.g., 3600)")
+			fmt.Println("  retry-on      - Only retry these exit codes (e.g., 1,75)")
+			fmt.Println("  fail-fast-on  - Never retry these exit codes (e.g., 2,64)")
+			fmt.Println("  reschedule-exit-code - Exit code to run again without using an attempt, 0 = off (e.g., 99)")
+			fmt.Println("  lease-timeout - Job lease length in seconds (e.g., 30)")
+			fmt.Println("  job-timeout   - Default job timeout in seconds, 0 = none (e.g., 300)")
+			fmt.Println("  output-limit  - Bytes of output kept per attempt (e.g., 65536)")
+			fmt.Println("  priority-aging - Seconds of waiting per +1 priority, 0 = strict (e.g., 60)")
 			return
 		}
+		if err != nil {
+			fmt.Println("❌ Error:", err)
+			return
+		}
+		numValue, _ := strconv.Atoi(value)
 
-		// Validate numeric values
-		numValue, err := strconv.Atoi(value)
+		st, err := openStore()
 		if err != nil {
-			fmt.Printf("❌ Error: value must be a number, got: %s\n", value)
+			fmt.Println("DB error:", err)
 			return
 		}
+		defer st.Close()
 
-		// Validate ranges
-		if key == "max-retries" && numValue < 0 {
-			fmt.Println("❌ Error: max-retries must be >= 0")
+		if _, err := newClient(st).SetConfig(cmd.Context(), configQueue, key, value); err != nil {
+			fmt.Println("Error saving configuration:", err)
 			return
 		}
 
-		if key == "backoff-base" && numValue < 1 {
-			fmt.Println("❌ Error: backoff-base must be >= 1")
+		if configQueue != "" {
+			fmt.Printf("✅ Configuration updated for queue %s: %s = %s\n", configQueue, key, value)
+		} else {
+			fmt.Printf("✅ Configuration updated: %s = %s\n", key, value)
+		}
+
+		// Show what this means
+		if key == "max-retries" {
+			fmt.Printf("   New jobs will retry up to %d times before moving to DLQ\n", numValue)
+		} else if key == "backoff-strategy" || key == "backoff-base" || key == "backoff-max" {
+			fmt.Printf("   Retry delays will be: %s\n", resolveBackoffPolicy(st, configQueue).Preview())
+		} else if key == "retry-on" {
+			if value == "" {
+				fmt.Println("   Jobs are retried whatever their exit code")
+			} else {
+				fmt.Printf("   Only exit codes %s are retried; other failures go to the DLQ\n", value)
+			}
+		} else if key == "fail-fast-on" {
+			if value != "" {
+				fmt.Printf("   Exit codes %s send jobs straight to the DLQ\n", value)
+			}
+		} else if key == "reschedule-exit-code" {
+			if numValue == 0 {
+				fmt.Println("   No exit code reschedules a job")
+			} else {
+				fmt.Printf("   Jobs exiting with %d run again later without using up an attempt\n", numValue)
+			}
+		} else if key == "lease-timeout" {
+			fmt.Printf("   Jobs are recovered if their worker misses heartbeats for %ds\n", numValue)
+		} else if key == "job-timeout" {
+			if numValue == 0 {
+				fmt.Println("   Jobs without --timeout may run forever")
+			} else {
+				fmt.Printf("   Jobs without --timeout are killed after %ds\n", numValue)
+			}
+		} else if key == "output-limit" {
+			fmt.Printf("   The last %d bytes of stdout and stderr are kept for each attempt\n", numValue)
+		} else if key == "priority-aging" {
+			if numValue == 0 {
+				fmt.Println("   Jobs run in strict priority order")
+			} else {
+				fmt.Printf("   Waiting jobs gain 1 priority every %ds\n", numValue)
+			}
+		}
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset [--queue <name>] <key>",
+	Short: "Remove a configuration value so the next level applies again",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		key := args[0]
+		if !slices.Contains(client.ConfigKeys(), key) {
+			fmt.Println("❌ Invalid key:", key)
 			return
 		}
 
-		db, err := sql.Open("sqlite", "data/queue.db")
+		c, err := openClient()
 		if err != nil {
 			fmt.Println("DB error:", err)
 			return
 		}
-		defer db.Close()
-
-		_, err = db.Exec(`
-            INSERT INTO config (Key, Value)
-            VALUES (?, ?)
-            ON CONFLICT(Key) DO UPDATE SET Value=excluded.Value
-        `, key, value)
+		defer c.Close()
 
+		cfg, err := c.UnsetConfig(cmd.Context(), configQueue, key)
 		if err != nil {
 			fmt.Println("Error saving configuration:", err)
 			return
 		}
 
-		fmt.Printf("✅ Configuration updated: %s = %s\n", key, value)
-
-		// Show what this means
-		if key == "max-retries" {
-			fmt.Printf("   Jobs will retry up to %d times before moving to DLQ\n", numValue)
-		} else if key == "backoff-base" {
-			fmt.Printf("   Retry delays will be: %ds, %ds, %ds, %ds...\n",
-				numValue, numValue*2, numValue*4, numValue*8)
-		}
+		fmt.Printf("✅ %s unset, now %s (%s)\n", key, cfg.Value, configSourceLabel(cfg.Source, configQueue))
 	},
 }
 
+func configSourceLabel(source, queue string) string {
+	if source == "queue" {
+		return "queue " + queue
+	}
+	return source
+}
+
 var configGetCmd = &cobra.Command{
-	Use:   "get",
-	Short: "Show all configuration values",
+	Use:   "get [--queue <name>]",
+	Short: "Show the effective configuration and where each value comes from",
+	Long: `Show the effective configuration and where each value comes from.
+
+Without --queue, machine-readable output (--output, --template) also lists
+every queue override, with its queue set.`,
 	Run: func(cmd *cobra.Command, args []string) {
 
-		db, err := sql.Open("sqlite", "data/queue.db")
+		st, err := openStore()
 		if err != nil {
 			fmt.Println("DB error:", err)
 			return
 		}
-		defer db.Close()
+		defer st.Close()
 
-		rows, err := db.Query(`SELECT Key, Value FROM config ORDER BY Key`)
+		c := newClient(st)
+		entries, err := c.Config(cmd.Context(), configQueue)
 		if err != nil {
 			fmt.Println("Error fetching config:", err)
 			return
 		}
-		defer rows.Close()
 
-		fmt.Println("\n===== CONFIGURATION =====")
+		var overrides []client.ConfigValue
+		if configQueue == "" {
+			overrides, err = c.QueueOverrides(cmd.Context())
+			if err != nil {
+				fmt.Println("Error fetching config:", err)
+				return
+			}
+		}
 
-		configMap := make(map[string]string)
-		for rows.Next() {
-			var key, value string
-			rows.Scan(&key, &value)
-			configMap[key] = value
+		if machineOutput() {
+			printOutputOrExit(append(entries, overrides...))
+			return
 		}
 
-		if len(configMap) == 0 {
-			fmt.Println("No configuration set yet. Using defaults:")
-			fmt.Println("  max-retries  = 3")
-			fmt.Println("  backoff-base = 2")
+		if configQueue != "" {
+			fmt.Printf("\n===== CONFIGURATION (queue %s) =====\n", configQueue)
 		} else {
-			// Show max-retries
-			if val, ok := configMap["max-retries"]; ok {
-				fmt.Printf("max-retries  = %s\n", val)
-				fmt.Println("  → Maximum retry attempts before moving to DLQ")
-			} else {
-				fmt.Println("max-retries  = 3 (default)")
-			}
+			fmt.Println("\n===== CONFIGURATION =====")
+		}
 
-			// Show backoff-base
-			if val, ok := configMap["backoff-base"]; ok {
-				fmt.Printf("backoff-base = %s\n", val)
-				numVal, _ := strconv.Atoi(val)
-				fmt.Printf("  → Retry delays: %ds, %ds, %ds, %ds...\n",
-					numVal, numVal*2, numVal*4, numVal*8)
-			} else {
-				fmt.Println("backoff-base = 2 (default)")
-				fmt.Println("  → Retry delays: 2s, 4s, 8s, 16s...")
+		for _, entry := range entries {
+			value := entry.Value
+			if value == "" {
+				value = `""`
 			}
+			fmt.Printf("%-20s = %s (%s)\n", entry.Key, value, configSourceLabel(entry.Source, configQueue))
+			fmt.Printf("  → %s\n", entry.Description)
 		}
 
-		fmt.Println("=========================\n")
+		fmt.Println("\nRetry delays:", resolveBackoffPolicy(st, configQueue).Preview())
+
+		if len(overrides) > 0 {
+			fmt.Println("\nQueue overrides:")
+		}
+		for _, entry := range overrides {
+			fmt.Printf("  %s: %s = %s\n", entry.Queue, entry.Key, entry.Value)
+		}
+
+		fmt.Print("=========================\n\n")
 	},
 }
 
@@ -151,4 +268,7 @@ func init() {
 	rootCmd.AddCommand(configCmd)
 	configCmd.AddCommand(configSetCmd)
 	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+
+	configCmd.PersistentFlags().StringVarP(&configQueue, "queue", "q", "", "Apply to jobs in this queue only")
 }
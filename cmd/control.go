@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/spf13/cobra"
+)
+
+var controlHost string
+var controlAll bool
+
+// sendWorkerCommand queues command for the given workers, every worker on
+// host, or every registered worker, and returns how many were addressed.
+func sendWorkerCommand(command string, workerIds []string, host string, all bool) (int, error) {
+	if len(workerIds) > 0 && (host != "" || all) || host != "" && all {
+		return 0, fmt.Errorf("use only one of worker IDs, --host or --all")
+	}
+	if len(workerIds) == 0 && host == "" && !all {
+		return 0, fmt.Errorf("give worker IDs, --host <name> or --all")
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return 0, err
+	}
+	defer st.Close()
+
+	workers, err := st.Workers()
+	if err != nil {
+		return 0, err
+	}
+
+	var targets []string
+	found := map[string]bool{}
+	for _, w := range workers {
+		switch {
+		case host != "" && w.Host != host:
+		case len(workerIds) > 0 && !slices.Contains(workerIds, w.Id):
+		default:
+			targets = append(targets, w.Id)
+			found[w.Id] = true
+		}
+	}
+	for _, id := range workerIds {
+		if !found[id] {
+			return 0, fmt.Errorf("no such worker: %s", id)
+		}
+	}
+
+	return len(targets), st.SendWorkerCommand(targets, command)
+}
+
+func newWorkerControlCmd(command, short string) *cobra.Command {
+	c := &cobra.Command{
+		Use:   command + " [workerId...]",
+		Short: short,
+		Run: func(cmd *cobra.Command, args []string) {
+			n, err := sendWorkerCommand(command, args, controlHost, controlAll)
+			if err != nil {
+				fmt.Println("❌ Error:", err)
+				return
+			}
+			if n == 0 {
+				fmt.Println("No matching workers.")
+				return
+			}
+			fmt.Printf("📨 Sent %s to %d worker(s). See \"queuectl status\" for acknowledgements.\n", command, n)
+		},
+	}
+	c.Flags().StringVar(&controlHost, "host", "", "Send to every worker on this host")
+	c.Flags().BoolVar(&controlAll, "all", false, "Send to every worker")
+	return c
+}
+
+func init() {
+	workerCmd.AddCommand(newWorkerControlCmd("stop", "Stop workers now; running jobs go back to pending"))
+	workerCmd.AddCommand(newWorkerControlCmd("drain", "Let workers finish their current job, then exit"))
+	workerCmd.AddCommand(newWorkerControlCmd("pause", "Stop workers from taking new jobs"))
+	workerCmd.AddCommand(newWorkerControlCmd("resume", "Let paused workers take jobs again"))
+}
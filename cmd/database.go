@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"queuectl/internal/db"
+	"queuectl/internal/store"
+	"queuectl/pkg/client"
+
+	"gopkg.in/yaml.v3"
+)
+
+var dbPath string
+
+// configFile is the optional per-user settings file. Unlike "queuectl
+// config", which is stored in the database, it says which database to use.
+type configFile struct {
+	DB           string `yaml:"db"`
+	Busy_timeout string `yaml:"busy_timeout"`
+}
+
+// configFilePath is $QUEUECTL_CONFIG, or queuectl/config.yaml in the user
+// config directory (~/.config on Linux).
+func configFilePath() string {
+	if path := os.Getenv("QUEUECTL_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "queuectl", "config.yaml")
+}
+
+// loadConfigFile reads the config file. A missing file is the same as an
+// empty one, except when $QUEUECTL_CONFIG names it explicitly.
+func loadConfigFile() (configFile, error) {
+	var cfg configFile
+
+	path := configFilePath()
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && os.Getenv("QUEUECTL_CONFIG") == "" {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return cfg, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	// A relative path in the file is relative to the file, not to wherever
+	// queuectl happens to run
+	if cfg.DB != "" && !db.IsPostgres(cfg.DB) && !filepath.IsAbs(cfg.DB) {
+		cfg.DB = filepath.Join(filepath.Dir(path), cfg.DB)
+	}
+	return cfg, nil
+}
+
+// databaseSettings resolves which database to use: --db, then $QUEUECTL_DB,
+// then the config file, then data/queue.db in the current directory. Each
+// may be an SQLite file or a postgres:// URL.
+func databaseSettings() (string, db.Options, error) {
+	var opts db.Options
+
+	cfg, err := loadConfigFile()
+	if err != nil {
+		return "", opts, err
+	}
+	if cfg.Busy_timeout != "" {
+		opts.BusyTimeout, err = time.ParseDuration(cfg.Busy_timeout)
+		if err != nil || opts.BusyTimeout <= 0 {
+			return "", opts, fmt.Errorf("invalid busy_timeout %q in config file (use e.g. 5s)", cfg.Busy_timeout)
+		}
+	}
+
+	path := dbPath
+	if path == "" {
+		path = os.Getenv("QUEUECTL_DB")
+	}
+	if path == "" {
+		path = cfg.DB
+	}
+	if path == "" {
+		path = db.DefaultPath
+	}
+	return path, opts, nil
+}
+
+// connectDB connects to the database commands should use, creating an
+// SQLite file if create is set, and returns it with its schema and a name
+// to show for it. It doesn't look at the schema.
+func connectDB(create bool) (*sql.DB, *db.Schema, string, error) {
+	dsn, opts, err := databaseSettings()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	opts.Create = create
+
+	database, schema, err := db.Connect(dsn, opts)
+	if errors.Is(err, db.ErrNotExist) {
+		return nil, nil, "", fmt.Errorf("%w (create it with \"queuectl db init\", or choose another with --db, QUEUECTL_DB or the config file)", err)
+	}
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return database, schema, db.Redact(dsn), nil
+}
+
+// openStore connects to the store the command should use. The database
+// must already exist, with the schema this binary was built for: a mistyped
+// path or running from the wrong directory should fail, not start an empty
+// queue, and upgrading the schema is left to "queuectl db migrate".
+func openStore() (store.Store, error) {
+	database, schema, _, err := connectDB(false)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := schema.Check(database); err != nil {
+		database.Close()
+		if errors.Is(err, db.ErrSchemaOutdated) {
+			return nil, fmt.Errorf("%w (run \"queuectl db migrate\")", err)
+		}
+		return nil, err
+	}
+	return store.New(database, schema), nil
+}
+
+// openClient is openStore for commands that only need what pkg/client
+// offers.
+func openClient() (*client.Client, error) {
+	st, err := openStore()
+	if err != nil {
+		return nil, err
+	}
+	return newClient(st), nil
+}
+
+// newClient returns a pkg/client Client for a store that is already open.
+func newClient(st store.Store) *client.Client {
+	return client.New(st, client.Options{})
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&dbPath, "db", "", "Database file or postgres:// URL (default $QUEUECTL_DB, the config file's db, or "+db.DefaultPath+")")
+}
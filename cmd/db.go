@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var migrateTo int
+
+type dbStatus struct {
+	Path           string          `json:"path" yaml:"path"`
+	Backend        string          `json:"backend" yaml:"backend"`
+	Version        int             `json:"version" yaml:"version"`
+	Latest_version int             `json:"latest_version" yaml:"latest_version"`
+	Migrations     []migrationInfo `json:"migrations" yaml:"migrations"`
+}
+
+type migrationInfo struct {
+	Version    int    `json:"version" yaml:"version"`
+	Name       string `json:"name" yaml:"name"`
+	Applied_at string `json:"applied_at,omitempty" yaml:"applied_at,omitempty"`
+}
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Manage the queue database",
+}
+
+var dbInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create the database if it doesn't exist",
+	Long: `Create the database, and the directory it is in, and its tables.
+For PostgreSQL the database must already exist and init creates the
+tables in it.
+
+Every other command refuses to run against a database that doesn't exist,
+so a typo in --db or running from the wrong directory can't silently start
+an empty queue. Running init against an existing database changes nothing;
+use "queuectl db migrate" to upgrade one.`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		dsn, _, err := databaseSettings()
+		if err != nil {
+			fmt.Println("❌ Error:", err)
+			return
+		}
+		if _, err := os.Stat(dsn); err == nil {
+			fmt.Println("Database already exists:", dsn)
+			return
+		}
+
+		database, schema, path, err := connectDB(true)
+		if err != nil {
+			fmt.Println("DB error:", err)
+			return
+		}
+		defer database.Close()
+
+		// A PostgreSQL database is created by its administrator; init
+		// only creates the tables, unless another init got there first
+		if version, err := schema.Version(database); err != nil {
+			fmt.Println("DB error:", err)
+			return
+		} else if version > 0 {
+			fmt.Println("Database already exists:", path)
+			return
+		}
+
+		if err := schema.Migrate(database); err != nil {
+			fmt.Println("Migration error:", err)
+			return
+		}
+
+		fmt.Printf("✅ Created database %s (schema version %d)\n", path, schema.LatestVersion())
+	},
+}
+
+var dbMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade the database schema",
+	Long: `Apply the schema migrations the database hasn't run yet, each in its
+own transaction, so a failed migration leaves the database at the last
+version that succeeded.
+
+Upgrade the database after installing a new queuectl; until then the other
+commands refuse to use it. Migrations only go forward, and a database
+upgraded by a newer queuectl can't be used by an older one. Migrating while
+workers run is safe, but stop them first if they are an older queuectl.`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		database, schema, path, err := connectDB(false)
+		if err != nil {
+			fmt.Println("DB error:", err)
+			return
+		}
+		defer database.Close()
+
+		target := schema.LatestVersion()
+		if cmd.Flags().Changed("to") {
+			target = migrateTo
+		}
+
+		from, err := schema.Version(database)
+		if err != nil {
+			fmt.Println("DB error:", err)
+			return
+		}
+
+		applied, err := schema.MigrateTo(database, target)
+		for _, m := range applied {
+			fmt.Printf("✅ Applied migration %d: %s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Println("❌ Error:", err)
+			return
+		}
+
+		if len(applied) == 0 {
+			fmt.Printf("Database %s is already at version %d\n", path, from)
+			return
+		}
+		fmt.Printf("Database %s migrated from version %d to %d\n", path, from, applied[len(applied)-1].Version)
+	},
+}
+
+var dbStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the database's schema version and its migrations",
+	Run: func(cmd *cobra.Command, args []string) {
+
+		database, schema, path, err := connectDB(false)
+		if err != nil {
+			fmt.Println("DB error:", err)
+			return
+		}
+		defer database.Close()
+
+		status := dbStatus{Path: path, Backend: schema.Name, Latest_version: schema.LatestVersion(), Migrations: []migrationInfo{}}
+		status.Version, err = schema.Version(database)
+		if err != nil {
+			fmt.Println("DB error:", err)
+			return
+		}
+		migrations, err := schema.Status(database)
+		if err != nil {
+			fmt.Println("DB error:", err)
+			return
+		}
+		for _, m := range migrations {
+			status.Migrations = append(status.Migrations, migrationInfo{Version: m.Version, Name: m.Name, Applied_at: m.Applied_at})
+		}
+
+		if machineOutput() {
+			printOutputOrExit(status)
+			return
+		}
+
+		fmt.Println("\n===== DATABASE =====")
+		fmt.Println("Path:   ", status.Path)
+		fmt.Println("Backend:", status.Backend)
+		switch {
+		case status.Version > status.Latest_version:
+			fmt.Printf("Schema:  version %d, newer than this queuectl (%d); upgrade queuectl\n", status.Version, status.Latest_version)
+		case status.Version < status.Latest_version:
+			fmt.Printf("Schema:  version %d of %d; run \"queuectl db migrate\"\n", status.Version, status.Latest_version)
+		default:
+			fmt.Printf("Schema:  version %d, up to date\n", status.Version)
+		}
+
+		fmt.Println("\n----- Migrations -----")
+		for _, m := range status.Migrations {
+			applied := m.Applied_at
+			if applied == "" {
+				applied = "pending"
+			}
+			fmt.Printf("%3d  %-20s %s\n", m.Version, m.Name, applied)
+		}
+		fmt.Println("====================")
+	},
+}
+
+func init() {
+	dbMigrateCmd.Flags().IntVar(&migrateTo, "to", 0, "Stop after this schema version (default: the latest)")
+
+	dbCmd.AddCommand(dbInitCmd)
+	dbCmd.AddCommand(dbMigrateCmd)
+	dbCmd.AddCommand(dbStatusCmd)
+	rootCmd.AddCommand(dbCmd)
+}
@@ -1,13 +1,12 @@
 package cmd
 
 import (
-	"database/sql"
+	"errors"
 	"fmt"
-	"os"
-	"time"
+
+	"queuectl/pkg/client"
 
 	"github.com/spf13/cobra"
-	_ "modernc.org/sqlite"
 )
 
 var dlqCmd = &cobra.Command{
@@ -21,58 +20,58 @@ var dlqListCmd = &cobra.Command{
 	Short: "List all dead jobs",
 	Run: func(cmd *cobra.Command, args []string) {
 
-		os.MkdirAll("data", 0755)
-
-		db, err := sql.Open("sqlite", "data/queue.db")
+		st, err := openStore()
 		if err != nil {
 			fmt.Println("DB error:", err)
 			return
 		}
-		defer db.Close()
-
-		fmt.Println("\n===== DEAD LETTER QUEUE =====")
+		defer st.Close()
 
-		rows, err := db.Query(`
-			SELECT Id, Command, Attempts, Max_retries, Updated_at
-			FROM jobs
-			WHERE State = 'dead'
-			ORDER BY Updated_at DESC
-		`)
+		paused, err := loadPausedQueues(st)
 		if err != nil {
 			fmt.Println("Query error:", err)
 			return
 		}
-		defer rows.Close()
 
-		found := false
+		c := newClient(st)
+		dead, _, err := c.List(cmd.Context(), client.ListOptions{State: "dead", Sort: "updated_at", Desc: true})
+		if err != nil {
+			fmt.Println("Query error:", err)
+			return
+		}
 
-		for rows.Next() {
-			found = true
+		jobs := []jobInfo{}
+		for _, j := range dead {
+			job := newJobInfo(j)
+			_, job.Queue_paused = paused[job.Queue]
+			jobs = append(jobs, job)
+		}
 
-			var Id, Command, Updated string
-			var Attempts, MaxRetries int
+		if machineOutput() {
+			printOutputOrExit(jobs)
+			return
+		}
 
-			err = rows.Scan(&Id, &Command, &Attempts, &MaxRetries, &Updated)
-			if err != nil {
-				fmt.Println("Error reading job:", err)
-				continue
-			}
+		fmt.Println("\n===== DEAD LETTER QUEUE =====")
 
+		for _, job := range jobs {
 			fmt.Printf(
-				"\nID: %s\nCommand: %s\nAttempts: %d\nMax_retries: %d\nUpdated: %s\n",
-				Id, Command, Attempts, MaxRetries, Updated,
+				"\nID: %s\nCommand: %s\nAttempts: %d\nMax_retries: %d\nReason: %s\nUpdated: %s\n",
+				job.Id, job.Command, job.Attempts, *job.Max_retries, job.Failure_reason, job.Updated_at,
 			)
 		}
 
-		if !found {
+		if len(jobs) == 0 {
 			fmt.Println("No dead jobs.")
 		}
 
-		fmt.Println("\n==============================\n")
+		fmt.Print("\n==============================\n\n")
 	},
 }
 
 
+var dlqRetryMaxRetries int
+
 var dlqRetryCmd = &cobra.Command{
 	Use:   "retry <jobId>",
 	Short: "Retry a dead job by resetting it to pending state",
@@ -81,60 +80,40 @@ var dlqRetryCmd = &cobra.Command{
 
 		jobId := args[0]
 
-		os.MkdirAll("data", 0755)
+		// Keep the job's own max_retries unless a new one was given
+		var opts client.RetryOptions
+		if cmd.Flags().Changed("max-retries") {
+			if dlqRetryMaxRetries < 0 {
+				fmt.Println("❌ Error: --max-retries must be >= 0")
+				return
+			}
+			opts.MaxRetries = &dlqRetryMaxRetries
+		}
 
-		db, err := sql.Open("sqlite", "data/queue.db")
+		c, err := openClient()
 		if err != nil {
 			fmt.Println("DB error:", err)
 			return
 		}
-		defer db.Close()
-
-		// Check if job exists & is dead
-		var currentState string
-		err = db.QueryRow(`
-			SELECT State FROM jobs WHERE Id = ?
-		`, jobId).Scan(&currentState)
+		defer c.Close()
 
-		if err == sql.ErrNoRows {
+		job, err := c.RetryDead(cmd.Context(), jobId, opts)
+		var stateErr *client.StateError
+		if errors.Is(err, client.ErrNotFound) {
 			fmt.Println("No such job:", jobId)
 			return
 		}
-		if err != nil {
-			fmt.Println("Error reading job:", err)
-			return
-		}
-
-		if currentState != "dead" {
+		if errors.As(err, &stateErr) {
 			fmt.Println("Job is not dead. Cannot retry via DLQ.")
-			fmt.Printf("Current state: %s\n", currentState)
+			fmt.Printf("Current state: %s\n", stateErr.State)
 			return
 		}
-
-		// Get configured max-retries from config table
-		var cfgMaxRetries int = 3 // default
-		row := db.QueryRow(`SELECT Value FROM config WHERE Key='max-retries'`)
-		row.Scan(&cfgMaxRetries)
-
-		// Reset job and requeue it with configured max_retries
-		_, err = db.Exec(`
-			UPDATE jobs
-			SET 
-				State = 'pending',
-				Attempts = 0,
-				Max_retries = ?,
-				Next_run_at = NULL,
-				WorkerId = NULL,
-				Updated_at = ?
-			WHERE Id = ?
-		`, cfgMaxRetries, time.Now().Format("2006-01-02 15:04:05"), jobId)
-
 		if err != nil {
 			fmt.Println("Error retrying job:", err)
 			return
 		}
 
-		fmt.Printf("✅ Job %s has been requeued with max_retries = %d\n", jobId, cfgMaxRetries)
+		fmt.Printf("✅ Job %s has been requeued with max_retries = %d\n", jobId, *job.Max_retries)
 	},
 }
 
@@ -142,4 +121,5 @@ func init() {
 	rootCmd.AddCommand(dlqCmd)
 	dlqCmd.AddCommand(dlqListCmd)
 	dlqCmd.AddCommand(dlqRetryCmd)
+	dlqRetryCmd.Flags().IntVar(&dlqRetryMaxRetries, "max-retries", 0, "Give the job a new max_retries instead of keeping its own")
 }
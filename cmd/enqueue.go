@@ -1,105 +1,271 @@
 package cmd
 
 import (
-	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"math/rand"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"queuectl/pkg/client"
+
 	"github.com/spf13/cobra"
-	_ "modernc.org/sqlite"
 )
 
 var userCommand string
+var userJobType string
+var userPayload string
+var userTimeout string
+var userBackoff string
+var userBackoffBase string
+var userBackoffMax string
+var userTags []string
+var userRetryOn string
+var userFailFastOn string
+var userMaxRetries int
+var userPriority int
+var userQueue string
+var userDelay string
+var userRunAt string
+var userSpecFile string
+var userSpecJSON string
 
 var enqueueCmd = &cobra.Command{
-	Use:   "enqueue",
+	Use:   "enqueue [-]",
 	Short: "Add a job to the queue",
-	Long:  "This command adds a new job to the SQLite database with all required fields.",
+	Long: `This command adds a new job to the database with all required fields.
+
+Jobs can also be given as structured specs:
+  --json '{"command": "..."}'   a single job as JSON
+  --file jobs.json|.jsonl|.yaml one job, an array/list of jobs, or one JSON job per line
+  -                             newline-delimited JSON jobs read from stdin
+
+Instead of a command, a job can have a type (--type) and a JSON payload
+(--payload). Only workers with a Go handler for the type claim it; see
+pkg/worker. A job with both runs its command on other workers,
+with the payload in $QUEUECTL_PAYLOAD.
+
+Spec fields: id, queue, command, type, payload, max_retries, priority, timeout, backoff,
+backoff_base, backoff_max, retry_on, fail_fast_on (lists of exit codes),
+run_at (RFC3339), env, tags (a list of labels to filter "list" by).
+All jobs from one invocation are added in a single transaction; if any
+of them is invalid, nothing is added.`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 
-		os.MkdirAll("data", 0755)
+		readStdin := len(args) == 1
+		if readStdin && args[0] != "-" {
+			fmt.Printf("❌ Error: unexpected argument %q (use - to read jobs from stdin)\n", args[0])
+			return
+		}
 
-		database, err := sql.Open("sqlite", "data/queue.db")
-		if err != nil {
-			fmt.Println("Error connecting to SQLite database:", err)
+		sources := 0
+		single := userCommand != "" || userJobType != "" || userPayload != ""
+		for _, set := range []bool{single, userSpecFile != "", userSpecJSON != "", readStdin} {
+			if set {
+				sources++
+			}
+		}
+		if sources > 1 {
+			fmt.Println("❌ Error: use only one of -c/--type, --file, --json or -")
 			return
 		}
-		defer database.Close()
-
-		// Create table with all columns including WorkerId and Next_run_at
-		createTableQuery := `
-		CREATE TABLE IF NOT EXISTS jobs (
-			Id TEXT PRIMARY KEY,
-			Command TEXT,
-			State TEXT,
-			Attempts INTEGER,
-			Max_retries INTEGER,
-			WorkerId TEXT,
-			Next_run_at TEXT DEFAULT CURRENT_TIMESTAMP,
-			Created_at TEXT,
-			Updated_at TEXT
-		);`
-
-		_, err = database.Exec(createTableQuery)
-		if err != nil {
-			fmt.Println("Error creating jobs table:", err)
+
+		// --delay is turned into an absolute run_at right away so that
+		// every job in the batch is due at the same moment
+		if userDelay != "" && userRunAt != "" {
+			fmt.Println("❌ Error: use only one of --delay or --run-at")
 			return
 		}
+		runAt := userRunAt
+		if userDelay != "" {
+			delay, err := time.ParseDuration(userDelay)
+			if err != nil || delay < 0 {
+				fmt.Printf("❌ Error: invalid --delay %q (use e.g. 30s, 10m, 2h)\n", userDelay)
+				return
+			}
+			runAt = formatTime(time.Now().Add(delay))
+		}
+
+		var retryOn, failFastOn []int
+		for _, flag := range []struct {
+			name  string
+			value string
+			dest  *[]int
+		}{
+			{"--retry-on", userRetryOn, &retryOn},
+			{"--fail-fast-on", userFailFastOn, &failFastOn},
+		} {
+			if flag.value == "" {
+				continue
+			}
+			codes, err := client.ParseExitCodes(flag.value)
+			if err != nil {
+				fmt.Printf("❌ Error: %s: %v\n", flag.name, err)
+				return
+			}
+			*flag.dest = codes
+		}
 
-		var charset = "abcdefghijklmnopqrstuvwxyz1234567890"
-		var jobId = ""
+		var entries []specEntry
+		var err error
 
-		for i := 0; i < 8; i++ {
-			jobId += string(charset[rand.Intn(len(charset))])
+		switch {
+		case userSpecJSON != "":
+			entries, err = parseJSONSpecs([]byte(userSpecJSON))
+		case userSpecFile != "":
+			var data []byte
+			data, err = os.ReadFile(userSpecFile)
+			if err != nil {
+				break
+			}
+			switch strings.ToLower(filepath.Ext(userSpecFile)) {
+			case ".yaml", ".yml":
+				entries, err = parseYAMLSpecs(data)
+			default:
+				entries, err = parseJSONSpecs(data)
+			}
+		case readStdin:
+			entries, err = parseJSONLines(os.Stdin)
+		default:
+			spec := client.JobSpec{Command: userCommand, Type: userJobType, Timeout: userTimeout}
+			if userPayload != "" {
+				spec.Payload = json.RawMessage(userPayload)
+			}
+			if spec.Command == "" && spec.Type == "" {
+				spec.Command = "command not found"
+			}
+			entries = []specEntry{{Where: "job", Spec: spec}}
 		}
 
-		var jobAttempts = 0
-		var job_maxRetries = 0
+		if err != nil {
+			fmt.Println("❌ Error reading jobs:", err)
+			os.Exit(1)
+		}
 
-		var job jobSpec
-		job.Id = jobId
+		// Validate everything before touching the database
+		var specs []client.JobSpec
+		var specEntries []int
+		problems := make([]error, len(entries))
+		for i, entry := range entries {
+			if entry.Err != nil {
+				problems[i] = entry.Err
+				continue
+			}
+			if userTimeout != "" && entry.Spec.Timeout == "" {
+				entry.Spec.Timeout = userTimeout
+			}
+			if userBackoff != "" && entry.Spec.Backoff == "" {
+				entry.Spec.Backoff = userBackoff
+			}
+			if userBackoffBase != "" && entry.Spec.Backoff_base == "" {
+				entry.Spec.Backoff_base = userBackoffBase
+			}
+			if userBackoffMax != "" && entry.Spec.Backoff_max == "" {
+				entry.Spec.Backoff_max = userBackoffMax
+			}
+			if retryOn != nil && entry.Spec.Retry_on == nil {
+				entry.Spec.Retry_on = retryOn
+			}
+			if failFastOn != nil && entry.Spec.Fail_fast_on == nil {
+				entry.Spec.Fail_fast_on = failFastOn
+			}
+			if userTags != nil && entry.Spec.Tags == nil {
+				entry.Spec.Tags = userTags
+			}
+			if runAt != "" && entry.Spec.Run_at == "" {
+				entry.Spec.Run_at = runAt
+			}
+			if userQueue != "" && entry.Spec.Queue == "" {
+				entry.Spec.Queue = userQueue
+			}
+			if cmd.Flags().Changed("priority") && entry.Spec.Priority == nil {
+				entry.Spec.Priority = &userPriority
+			}
+			if cmd.Flags().Changed("max-retries") && entry.Spec.Max_retries == nil {
+				entry.Spec.Max_retries = &userMaxRetries
+			}
 
-		if userCommand == "" {
-			job.Command = "command not found"
-		} else {
-			job.Command = userCommand
-		}
-
-		job.State = "pending"
-		job.Attempts = jobAttempts
-		job.Max_retries = job_maxRetries
-		job.Created_at = time.Now().Format("2006-01-02 15:04:05")
-		job.Updated_at = time.Now().Format("2006-01-02 15:04:05")
-
-		insertQuery := `
-			INSERT INTO jobs (
-				Id, Command, State, Attempts, Max_retries, WorkerId, Next_run_at, Created_at, Updated_at
-			) VALUES (?, ?, ?, ?, ?, NULL, datetime('now'), ?, ?)
-		`
-
-		_, err = database.Exec(
-			insertQuery,
-			job.Id,
-			job.Command,
-			job.State,
-			job.Attempts,
-			job.Max_retries,
-			job.Created_at,
-			job.Updated_at,
-		)
+			specs = append(specs, entry.Spec)
+			specEntries = append(specEntries, i)
+		}
 
+		var invalidJobs *client.InvalidJobsError
+		if errors.As(client.ValidateBatch(specs), &invalidJobs) {
+			for _, job := range invalidJobs.Jobs {
+				problems[specEntries[job.Index]] = job.Err
+			}
+		}
+
+		var invalid []string
+		for i, err := range problems {
+			var dup *client.DuplicateIdError
+			switch {
+			case errors.As(err, &dup):
+				first := entries[specEntries[dup.First]].Where
+				invalid = append(invalid, fmt.Sprintf("%s: duplicate id %q (also used by %s)", entries[i].Where, dup.Id, first))
+			case err != nil:
+				invalid = append(invalid, fmt.Sprintf("%s: %v", entries[i].Where, err))
+			}
+		}
+
+		if len(invalid) > 0 {
+			fmt.Printf("❌ %d invalid job(s), nothing was enqueued:\n", len(invalid))
+			for _, e := range invalid {
+				fmt.Println("  " + e)
+			}
+			os.Exit(1)
+		}
+
+		if len(specs) == 0 {
+			fmt.Println("No jobs to enqueue.")
+			return
+		}
+
+		c, err := openClient()
+		if err != nil {
+			fmt.Println("Error connecting to database:", err)
+			return
+		}
+		defer c.Close()
+
+		jobs, err := c.EnqueueBatch(cmd.Context(), specs)
 		if err != nil {
-			fmt.Println("Error inserting values:", err)
+			fmt.Println("Error inserting", err)
+			fmt.Println("Nothing was enqueued.")
 			return
 		}
 
-		fmt.Println("Job added successfully with ID:", job.Id)
+		if len(jobs) == 1 {
+			fmt.Println("Job added successfully with ID:", jobs[0].Id)
+		} else {
+			fmt.Printf("✅ %d jobs added successfully\n", len(jobs))
+		}
+		if runAt != "" {
+			fmt.Println("Scheduled to run at:", jobs[0].Next_run_at)
+		}
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(enqueueCmd)
 	enqueueCmd.Flags().StringVarP(&userCommand, "command", "c", "", "Command for the job")
+	enqueueCmd.Flags().StringVar(&userJobType, "type", "", "Job type, run by a worker with a Go handler for it instead of a command")
+	enqueueCmd.Flags().StringVar(&userPayload, "payload", "", "JSON input for the job's handler (e.g. '{\"to\": \"ops@example.com\"}')")
+	enqueueCmd.Flags().IntVar(&userMaxRetries, "max-retries", 0, "Attempts before the job moves to the DLQ (default: config max-retries)")
+	enqueueCmd.Flags().StringVarP(&userQueue, "queue", "q", "", "Queue to add the job to (default: \"default\")")
+	enqueueCmd.Flags().IntVarP(&userPriority, "priority", "p", 0, "Higher priority jobs run first (may be negative)")
+	enqueueCmd.Flags().StringVar(&userDelay, "delay", "", "Wait this long before the job may run (e.g. 10m)")
+	enqueueCmd.Flags().StringVar(&userRunAt, "run-at", "", "Don't run the job before this RFC3339 time (e.g. 2026-11-01T03:00:00Z)")
+	enqueueCmd.Flags().StringVar(&userTimeout, "timeout", "", "Kill the job if it runs longer than this (e.g. 30s, 5m)")
+	enqueueCmd.Flags().StringVar(&userBackoff, "backoff", "", "Retry delay strategy: fixed, linear, exponential, full-jitter or decorrelated-jitter (default: config backoff-strategy)")
+	enqueueCmd.Flags().StringVar(&userBackoffBase, "backoff-base", "", "First retry delay (e.g. 5s; default: config backoff-base)")
+	enqueueCmd.Flags().StringVar(&userBackoffMax, "backoff-max", "", "Longest retry delay (e.g. 10m; default: config backoff-max)")
+	enqueueCmd.Flags().StringVar(&userRetryOn, "retry-on", "", "Only retry these exit codes, e.g. 1,75 (default: config retry-on)")
+	enqueueCmd.Flags().StringVar(&userFailFastOn, "fail-fast-on", "", "Send the job straight to the DLQ on these exit codes, e.g. 2,64 (default: config fail-fast-on)")
+	enqueueCmd.Flags().StringSliceVarP(&userTags, "tag", "t", nil, "Label the job; repeat or separate with commas (e.g. -t billing -t customer:42)")
+	enqueueCmd.Flags().StringVarP(&userSpecFile, "file", "f", "", "Read job spec(s) from a JSON, JSONL or YAML file")
+	enqueueCmd.Flags().StringVar(&userSpecJSON, "json", "", "Job spec as a JSON object")
 }
@@ -0,0 +1,39 @@
+package cmd
+
+import "queuectl/internal/store"
+
+// jobEvent is one entry of a job's history as "show" outputs it; see
+// store.Event.
+type jobEvent struct {
+	At         string `json:"at" yaml:"at"`
+	Event      string `json:"event" yaml:"event"`
+	From_state string `json:"from_state,omitempty" yaml:"from_state,omitempty"`
+	To_state   string `json:"to_state,omitempty" yaml:"to_state,omitempty"`
+	Attempt    int    `json:"attempt,omitempty" yaml:"attempt,omitempty"`
+	Worker_id  string `json:"worker_id,omitempty" yaml:"worker_id,omitempty"`
+	Actor      string `json:"actor,omitempty" yaml:"actor,omitempty"`
+	Detail     string `json:"detail,omitempty" yaml:"detail,omitempty"`
+}
+
+// fetchJobEvents returns the history of a job, oldest first.
+func fetchJobEvents(st store.Store, jobId string) ([]jobEvent, error) {
+	stored, err := st.JobEvents(jobId)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []jobEvent{}
+	for _, e := range stored {
+		events = append(events, jobEvent{
+			At:         e.At,
+			Event:      e.Event,
+			From_state: e.FromState,
+			To_state:   e.ToState,
+			Attempt:    e.Attempt,
+			Worker_id:  e.WorkerId,
+			Actor:      e.Actor,
+			Detail:     e.Detail,
+		})
+	}
+	return events, nil
+}
@@ -0,0 +1,18 @@
+package cmd
+
+import (
+	"queuectl/pkg/client"
+)
+
+// jobInfo is a stored job as the read commands output it, along with
+// whether its queue is paused.
+type jobInfo struct {
+	client.Job `yaml:",inline"`
+
+	Queue_paused bool `json:"queue_paused" yaml:"queue_paused"`
+}
+
+// newJobInfo converts a job for output.
+func newJobInfo(j client.Job) jobInfo {
+	return jobInfo{Job: j}
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"queuectl/pkg/client"
+
+	"gopkg.in/yaml.v3"
+)
+
+// specEntry is one job read from --json, --file or stdin, labelled with
+// where it came from so validation errors can point back at it. Err is
+// set when the entry could not be decoded.
+type specEntry struct {
+	Where string
+	Spec  client.JobSpec
+	Err   error
+}
+
+func decodeJSONSpec(data []byte, spec *client.JobSpec) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	// Keep payload numbers exactly as given rather than as float64
+	dec.UseNumber()
+
+	if err := dec.Decode(spec); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("unexpected data after the job object")
+	}
+	return nil
+}
+
+// parseJSONSpecs accepts a single job object, an array of job objects, or
+// newline-delimited JSON with one job object per line.
+func parseJSONSpecs(data []byte) ([]specEntry, error) {
+	trimmed := bytes.TrimSpace(data)
+
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		var raw []json.RawMessage
+		if err := json.Unmarshal(trimmed, &raw); err != nil {
+			return nil, err
+		}
+
+		var entries []specEntry
+		for i, item := range raw {
+			where := fmt.Sprintf("item %d", i+1)
+			var spec client.JobSpec
+			err := decodeJSONSpec(item, &spec)
+			entries = append(entries, specEntry{Where: where, Spec: spec, Err: err})
+		}
+		return entries, nil
+	}
+
+	// A single (possibly pretty-printed) object
+	var spec client.JobSpec
+	if err := decodeJSONSpec(trimmed, &spec); err == nil {
+		return []specEntry{{Where: "job", Spec: spec}}, nil
+	}
+
+	return parseJSONLines(bytes.NewReader(data))
+}
+
+// parseJSONLines reads newline-delimited JSON, skipping blank lines.
+func parseJSONLines(r io.Reader) ([]specEntry, error) {
+	var entries []specEntry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		where := fmt.Sprintf("line %d", lineNum)
+		var spec client.JobSpec
+		err := decodeJSONSpec(line, &spec)
+		entries = append(entries, specEntry{Where: where, Spec: spec, Err: err})
+	}
+	if err := scanner.Err(); err != nil {
+		return entries, fmt.Errorf("line %d: %w", lineNum+1, err)
+	}
+
+	return entries, nil
+}
+
+// parseYAMLSpecs accepts a single job mapping or a list of them.
+func parseYAMLSpecs(data []byte) ([]specEntry, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	root := doc.Content[0]
+	items := []*yaml.Node{root}
+	if root.Kind == yaml.SequenceNode {
+		items = root.Content
+	}
+
+	var entries []specEntry
+	for i, item := range items {
+		where := fmt.Sprintf("line %d", item.Line)
+		if root.Kind == yaml.SequenceNode {
+			where = fmt.Sprintf("item %d (line %d)", i+1, item.Line)
+		}
+
+		// Round-trip through a decoder so unknown fields are rejected
+		// like they are for JSON
+		raw, err := yaml.Marshal(item)
+		if err != nil {
+			entries = append(entries, specEntry{Where: where, Err: err})
+			continue
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(raw))
+		dec.KnownFields(true)
+
+		var spec client.JobSpec
+		err = dec.Decode(&spec)
+		entries = append(entries, specEntry{Where: where, Spec: spec, Err: err})
+	}
+
+	return entries, nil
+}
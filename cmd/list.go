@@ -1,96 +1,280 @@
 package cmd
 
 import (
-	"database/sql"
+	"errors"
 	"fmt"
 	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"queuectl/pkg/client"
 
 	"github.com/spf13/cobra"
-	_ "modernc.org/sqlite"
 )
 
 var checkStateCmd string
+var listQueue string
+var listCommand string
+var listMatch string
+var listWorker string
+var listType string
+var listTags []string
+var listCreatedAfter string
+var listCreatedBefore string
+var listUpdatedAfter string
+var listUpdatedBefore string
+var listMinAttempts int
+var listMaxAttempts int
+var listSort string
+var listLimit int
+var listOffset int
+var listAfter string
+var listCount bool
+
+// parseListSort parses --sort, e.g. "priority:desc". The direction
+// defaults to ascending.
+func parseListSort(value string) (string, bool, error) {
+	field, dir, _ := strings.Cut(value, ":")
+	if fields := client.SortFields(); !slices.Contains(fields, field) {
+		return "", false, fmt.Errorf("cannot sort by %q (use one of: %s)", field, strings.Join(fields, ", "))
+	}
+	switch dir {
+	case "", "asc":
+		return field, false, nil
+	case "desc":
+		return field, true, nil
+	}
+	return "", false, fmt.Errorf("invalid sort direction %q (use asc or desc)", dir)
+}
+
+// parseTimeFilter reads a time given as RFC3339 or as a duration meaning
+// that long ago, e.g. "2h".
+func parseTimeFilter(flag, value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid %s %q (use RFC3339, e.g. 2026-11-01T03:00:00Z, or a duration ago, e.g. 2h)", flag, value)
+}
+
+// jobCount is the result of "list --count".
+type jobCount struct {
+	Count int `json:"count" yaml:"count"`
+}
 
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List jobs from the database",
+	Long: `List jobs, oldest first, optionally filtered, sorted and paged.
+
+Filters combine with AND. --created-*/--updated-* take an RFC3339 time or
+a duration meaning that long ago (e.g. --created-after 2h).
+
+--sort takes a field and an optional direction, e.g. --sort priority:desc.
+Fields: id, queue, command, state, attempts, max_retries, priority,
+created_at, updated_at, next_run_at, worker_id.
+
+With --limit, a page that isn't the last ends with a cursor to pass to
+--after for the next page, using the same filters and --sort. Unlike
+--offset, cursors stay correct while jobs are being added.`,
 	Run: func(cmd *cobra.Command, args []string) {
 
-		os.MkdirAll("data", 0755)
+		sortField, sortDesc := "created_at", false
+		if checkStateCmd == "scheduled" {
+			sortField = "next_run_at"
+		}
+		if listSort != "" {
+			var err error
+			sortField, sortDesc, err = parseListSort(listSort)
+			if err != nil {
+				fmt.Println("❌ Error:", err)
+				return
+			}
+		}
+		if listLimit < 0 || listOffset < 0 {
+			fmt.Println("❌ Error: --limit and --offset must be >= 0")
+			return
+		}
+		if listAfter != "" && listOffset > 0 {
+			fmt.Println("❌ Error: use only one of --after or --offset")
+			return
+		}
 
-		db, err := sql.Open("sqlite", "data/queue.db")
+		opts := client.ListOptions{
+			State:    checkStateCmd,
+			Queue:    listQueue,
+			WorkerId: listWorker,
+			Type:     listType,
+			Command:  listCommand,
+			Match:    listMatch,
+			Tags:     listTags,
+			Sort:     sortField,
+			Desc:     sortDesc,
+			Limit:    listLimit,
+			Offset:   listOffset,
+			After:    listAfter,
+		}
+		if listMatch != "" {
+			if _, err := regexp.Compile(listMatch); err != nil {
+				fmt.Println("❌ Error: invalid --match:", err)
+				return
+			}
+		}
+		for _, t := range []struct {
+			flag  string
+			value string
+			dest  *time.Time
+		}{
+			{"--created-after", listCreatedAfter, &opts.CreatedAfter},
+			{"--created-before", listCreatedBefore, &opts.CreatedBefore},
+			{"--updated-after", listUpdatedAfter, &opts.UpdatedAfter},
+			{"--updated-before", listUpdatedBefore, &opts.UpdatedBefore},
+		} {
+			if t.value == "" {
+				continue
+			}
+			parsed, err := parseTimeFilter(t.flag, t.value)
+			if err != nil {
+				fmt.Println("❌ Error:", err)
+				return
+			}
+			*t.dest = parsed
+		}
+		if cmd.Flags().Changed("min-attempts") {
+			opts.MinAttempts = &listMinAttempts
+		}
+		if cmd.Flags().Changed("max-attempts") {
+			opts.MaxAttempts = &listMaxAttempts
+		}
+
+		st, err := openStore()
 		if err != nil {
 			fmt.Println("DB error:", err)
 			return
 		}
-		defer db.Close()
+		defer st.Close()
+		c := newClient(st)
 
-		var query string
-		if checkStateCmd == "" {
-			query = `
-				SELECT Id, Command, State, Attempts, Max_retries,
-				       Created_at, Updated_at, Next_run_at, WorkerId
-				FROM jobs
-			`
-		} else {
-			query = `
-				SELECT Id, Command, State, Attempts, Max_retries,
-				       Created_at, Updated_at, Next_run_at, WorkerId
-				FROM jobs
-				WHERE State = ?
-			`
+		if listCount {
+			var result jobCount
+			if result.Count, err = c.Count(cmd.Context(), opts); err != nil {
+				fmt.Println("Query error:", err)
+				return
+			}
+			if machineOutput() {
+				printOutputOrExit(result)
+			} else {
+				fmt.Printf("%d job(s)\n", result.Count)
+			}
+			return
 		}
 
-		var rows *sql.Rows
-		if checkStateCmd == "" {
-			rows, err = db.Query(query)
-		} else {
-			rows, err = db.Query(query, checkStateCmd)
+		paused, err := loadPausedQueues(st)
+		if err != nil {
+			fmt.Println("Query error:", err)
+			return
 		}
 
+		found, next, err := c.List(cmd.Context(), opts)
+		if errors.Is(err, client.ErrInvalidCursor) {
+			fmt.Println("❌ Error: --after:", err)
+			return
+		}
 		if err != nil {
 			fmt.Println("Query error:", err)
 			return
 		}
-		defer rows.Close()
 
-		for rows.Next() {
-			var Id, Command, State, Created_at, Updated_at string
-			var Next_run_at sql.NullString
-			var WorkerId sql.NullString
-			var Attempts, Max_retries int
-
-			err = rows.Scan(&Id, &Command, &State, &Attempts, &Max_retries,
-				&Created_at, &Updated_at, &Next_run_at, &WorkerId)
+		jobs := []jobInfo{}
+		for _, j := range found {
+			job := newJobInfo(j)
+			_, job.Queue_paused = paused[job.Queue]
+			jobs = append(jobs, job)
+		}
 
-			if err != nil {
-				fmt.Println("Row error:", err)
-				continue
+		if machineOutput() {
+			printOutputOrExit(jobs)
+			if next != "" {
+				fmt.Fprintln(os.Stderr, "Next page: --after", next)
 			}
+			return
+		}
 
+		for _, job := range jobs {
 			worker := "none"
-			if WorkerId.Valid && WorkerId.String != "" {
-				worker = WorkerId.String
+			if job.Worker_id != "" {
+				worker = job.Worker_id
+			}
+
+			queue := job.Queue
+			if job.Queue_paused {
+				queue += "   " + paused[job.Queue].describe()
 			}
 
 			fmt.Printf(`
 ID: %s
+Queue: %s
 Command: %s
 State: %s
 Attempts: %d
 Max Retries: %d
+Priority: %d
 Next Run At: %s
 Worker: %s
 Created At: %s
 Updated At: %s
 `,
-				Id, Command, State, Attempts, Max_retries,
-				Next_run_at.String, worker, Created_at, Updated_at)
+				job.Id, queue, job.Command, job.State, job.Attempts, *job.Max_retries, *job.Priority,
+				job.Next_run_at, worker, job.Created_at, job.Updated_at)
+
+			if job.Type != "" {
+				fmt.Println("Type:", job.Type)
+			}
+
+			if len(job.Tags) > 0 {
+				fmt.Println("Tags:", strings.Join(job.Tags, ", "))
+			}
+
+			if job.State == "cancelled" || job.Cancel_requested_at != "" {
+				cancelled := "Cancelled By: " + job.Cancelled_by
+				if job.State != "cancelled" {
+					cancelled += " (waiting for the worker to stop it)"
+				}
+				fmt.Println(cancelled)
+				if job.Cancel_reason != "" {
+					fmt.Println("Cancel Reason:", job.Cancel_reason)
+				}
+			}
+		}
+
+		if next != "" {
+			fmt.Println("\nNext page: --after", next)
 		}
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(listCmd)
-	listCmd.Flags().StringVarP(&checkStateCmd, "state", "s", "", "Filter jobs by state")
+	listCmd.Flags().StringVarP(&checkStateCmd, "state", "s", "", "Filter jobs by state (or \"scheduled\" for pending jobs not due yet)")
+	listCmd.Flags().StringVarP(&listQueue, "queue", "q", "", "Only show jobs in this queue")
+	listCmd.Flags().StringVarP(&listCommand, "command", "c", "", "Only show jobs whose command contains this text")
+	listCmd.Flags().StringVarP(&listMatch, "match", "m", "", "Only show jobs whose command matches this regular expression")
+	listCmd.Flags().StringVarP(&listWorker, "worker", "w", "", "Only show jobs whose latest attempt ran on this worker")
+	listCmd.Flags().StringVar(&listType, "type", "", "Only show jobs of this type")
+	listCmd.Flags().StringSliceVarP(&listTags, "tag", "t", nil, "Only show jobs with this tag; repeat to require several")
+	listCmd.Flags().StringVar(&listCreatedAfter, "created-after", "", "Only show jobs created at or after this time")
+	listCmd.Flags().StringVar(&listCreatedBefore, "created-before", "", "Only show jobs created before this time")
+	listCmd.Flags().StringVar(&listUpdatedAfter, "updated-after", "", "Only show jobs updated at or after this time")
+	listCmd.Flags().StringVar(&listUpdatedBefore, "updated-before", "", "Only show jobs updated before this time")
+	listCmd.Flags().IntVar(&listMinAttempts, "min-attempts", 0, "Only show jobs with at least this many attempts")
+	listCmd.Flags().IntVar(&listMaxAttempts, "max-attempts", 0, "Only show jobs with at most this many attempts")
+	listCmd.Flags().StringVar(&listSort, "sort", "", "Sort by this field, e.g. priority:desc (default: created_at)")
+	listCmd.Flags().IntVarP(&listLimit, "limit", "n", 0, "Show at most this many jobs (0 = all)")
+	listCmd.Flags().IntVar(&listOffset, "offset", 0, "Skip this many jobs first")
+	listCmd.Flags().StringVar(&listAfter, "after", "", "Show the page after this cursor (printed at the end of the previous page)")
+	listCmd.Flags().BoolVar(&listCount, "count", false, "Only print how many jobs match")
 }
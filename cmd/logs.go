@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"queuectl/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var logsAttempt int
+var logsFollow bool
+
+func printAttemptHeader(a store.Attempt) {
+	fmt.Printf("\n----- Attempt %d (worker: %s) -----\n", a.Attempt, a.WorkerId.String)
+	fmt.Printf("Started: %s\n", a.StartedAt.String)
+}
+
+func printAttemptFooter(a store.Attempt) {
+	fmt.Printf("Finished: %s\n", a.FinishedAt.String)
+	if a.ExitCode.Valid {
+		fmt.Printf("Exit code: %d\n", a.ExitCode.Int64)
+	} else {
+		fmt.Println("Exit code: unknown (worker lost)")
+	}
+	if a.Signal.Valid {
+		fmt.Printf("Signal: %s\n", a.Signal.String)
+	}
+}
+
+func printAttemptLog(a store.Attempt) {
+	printAttemptHeader(a)
+
+	if a.Stdout.String != "" {
+		fmt.Println("[stdout]")
+		fmt.Print(a.Stdout.String)
+		if !strings.HasSuffix(a.Stdout.String, "\n") {
+			fmt.Println()
+		}
+	}
+	if a.Stderr.String != "" {
+		fmt.Println("[stderr]")
+		fmt.Print(a.Stderr.String)
+		if !strings.HasSuffix(a.Stderr.String, "\n") {
+			fmt.Println()
+		}
+	}
+
+	if a.FinishedAt.Valid {
+		printAttemptFooter(a)
+	} else {
+		fmt.Println("Still running...")
+	}
+}
+
+// followAttemptLogs polls job_attempts and prints output as the worker
+// flushes it, until the job reaches a final state (or the requested
+// attempt finishes).
+func followAttemptLogs(st store.Store, jobId string, attempt int) {
+	type progress struct {
+		stdout, stderr string
+		finished       bool
+	}
+	seen := map[int64]*progress{}
+
+	// printNew writes whatever was added since the last poll. Once output
+	// passes output-limit the stored text is trimmed from the front, so
+	// fall back to reprinting the latest tail.
+	printNew := func(prev *string, cur string, out *os.File) {
+		if strings.HasPrefix(cur, *prev) {
+			fmt.Fprint(out, cur[len(*prev):])
+		} else {
+			fmt.Fprintln(out, "...[output rotated, showing latest]...")
+			fmt.Fprint(out, cur)
+		}
+		*prev = cur
+	}
+
+	for {
+		logs, err := st.JobAttempts(jobId, attempt)
+		if err != nil {
+			fmt.Println("Error reading logs:", err)
+			return
+		}
+
+		for _, a := range logs {
+			p, ok := seen[a.Id]
+			if !ok {
+				p = &progress{}
+				seen[a.Id] = p
+				printAttemptHeader(a)
+			}
+			if p.finished {
+				continue
+			}
+
+			printNew(&p.stdout, a.Stdout.String, os.Stdout)
+			printNew(&p.stderr, a.Stderr.String, os.Stderr)
+
+			if a.FinishedAt.Valid {
+				p.finished = true
+				printAttemptFooter(a)
+			}
+		}
+
+		if attempt > 0 {
+			if len(logs) > 0 && logs[0].FinishedAt.Valid {
+				return
+			}
+		} else {
+			job, _ := st.GetJob(jobId)
+
+			allFinished := true
+			for _, p := range seen {
+				allFinished = allFinished && p.finished
+			}
+			if (job.State == "completed" || job.State == "dead") && allFinished {
+				return
+			}
+		}
+
+		time.Sleep(time.Second)
+	}
+}
+
+var logsCmd = &cobra.Command{
+	Use:   "logs <jobId>",
+	Short: "Show captured output and exit codes for a job's attempts",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		jobId := args[0]
+
+		st, err := openStore()
+		if err != nil {
+			fmt.Println("DB error:", err)
+			return
+		}
+		defer st.Close()
+
+		_, err = st.GetJob(jobId)
+		if errors.Is(err, sql.ErrNoRows) {
+			fmt.Println("No such job:", jobId)
+			return
+		}
+		if err != nil {
+			fmt.Println("Error reading job:", err)
+			return
+		}
+
+		fmt.Printf("\n===== LOGS: %s =====\n", jobId)
+
+		if logsFollow {
+			followAttemptLogs(st, jobId, logsAttempt)
+			return
+		}
+
+		logs, err := st.JobAttempts(jobId, logsAttempt)
+		if err != nil {
+			fmt.Println("Error reading logs:", err)
+			return
+		}
+
+		if len(logs) == 0 {
+			if logsAttempt > 0 {
+				fmt.Printf("No attempt %d recorded for this job.\n", logsAttempt)
+			} else {
+				fmt.Println("No attempts recorded yet.")
+			}
+			return
+		}
+
+		for _, a := range logs {
+			printAttemptLog(a)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+	logsCmd.Flags().IntVarP(&logsAttempt, "attempt", "a", 0, "Only show this attempt number")
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Keep printing output until the job finishes")
+}
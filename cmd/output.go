@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var outputFormat string
+var outputTemplate string
+
+var outputFormats = []string{"table", "json", "jsonl", "yaml", "csv"}
+
+func validateOutputFormat(format string) error {
+	for _, f := range outputFormats {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid --output %q (use one of: %s)", format, strings.Join(outputFormats, ", "))
+}
+
+// machineOutput reports whether --output or --template asked for something
+// other than the human-readable table.
+func machineOutput() bool {
+	return outputFormat != "table" || outputTemplate != ""
+}
+
+// printOutput writes v, a result struct or a slice of them, in the format
+// picked with --output, or through --template. Field names are the json
+// tags of the result structs, which are kept stable for scripts.
+//
+// For a slice, jsonl writes one object per line and --template is run once
+// per element. csv needs a slice of flat structs; nested values are written
+// as JSON.
+func printOutput(w io.Writer, v any) error {
+	if outputTemplate != "" {
+		return printTemplate(w, v)
+	}
+
+	switch outputFormat {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "jsonl":
+		enc := json.NewEncoder(w)
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice {
+			return enc.Encode(v)
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		defer enc.Close()
+		return enc.Encode(v)
+	case "csv":
+		return printCSV(w, v)
+	}
+	return validateOutputFormat(outputFormat)
+}
+
+func printTemplate(w io.Writer, v any) error {
+	tmpl, err := template.New("output").Parse(outputTemplate)
+	if err != nil {
+		return fmt.Errorf("invalid --template: %w", err)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		if err := tmpl.Execute(w, v); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(w)
+		return err
+	}
+
+	for i := 0; i < rv.Len(); i++ {
+		if err := tmpl.Execute(w, rv.Index(i).Interface()); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printCSV(w io.Writer, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return fmt.Errorf("csv output is only available for lists; use json, jsonl or yaml")
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader(rv.Type().Elem()))
+	for i := 0; i < rv.Len(); i++ {
+		cw.Write(csvRecord(rv.Index(i)))
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvFields lists the exported fields of struct type t with their column
+// names, flattening embedded structs the way encoding/json does.
+func csvFields(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		if csvColumn(f) == "-" {
+			continue
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func csvColumn(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+func csvHeader(t reflect.Type) []string {
+	var header []string
+	for _, f := range csvFields(t) {
+		header = append(header, csvColumn(f))
+	}
+	return header
+}
+
+func csvRecord(v reflect.Value) []string {
+	var record []string
+	for _, f := range csvFields(v.Type()) {
+		record = append(record, csvValue(v.FieldByIndex(f.Index)))
+	}
+	return record
+}
+
+func csvValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return ""
+		}
+		return csvValue(v.Elem())
+	case reflect.Slice, reflect.Map, reflect.Struct:
+		if (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0 {
+			return ""
+		}
+		data, err := json.Marshal(v.Interface())
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// printOutputOrExit is printOutput for command Run functions, which have
+// no error to return.
+func printOutputOrExit(v any) {
+	if err := printOutput(os.Stdout, v); err != nil {
+		fmt.Fprintln(os.Stderr, "❌ Error:", err)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if err := validateOutputFormat(outputFormat); err != nil {
+			cmd.SilenceUsage = true
+			return err
+		}
+		return nil
+	}
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "table", "Output format of list, status, dlq list and config get: "+strings.Join(outputFormats, ", "))
+	rootCmd.PersistentFlags().StringVar(&outputTemplate, "template", "", "Go text/template applied to each result (e.g. '{{.Id}} {{.State}}')")
+}
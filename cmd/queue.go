@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"queuectl/internal/store"
+	"queuectl/pkg/client"
+
+	"github.com/spf13/cobra"
+)
+
+var queuePauseFor string
+var queuePauseUntil string
+var queuePauseReason string
+
+var queueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "Manage whole queues",
+}
+
+var queuePauseCmd = &cobra.Command{
+	Use:   "pause <name>",
+	Short: "Stop workers from taking jobs from a queue",
+	Long: `Stop workers from taking jobs from a queue. Jobs already running finish
+as usual and new jobs can still be enqueued; they wait until the queue is
+resumed. With --for or --until the queue resumes by itself at that time.
+
+Examples:
+  queuectl queue pause emails --reason "SMTP outage"
+  queuectl queue pause reports --for 30m`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		queue := args[0]
+		if err := client.ValidateQueueName(queue); err != nil {
+			fmt.Println("❌ Error:", err)
+			return
+		}
+
+		var resumeAt string
+		switch {
+		case queuePauseFor != "" && queuePauseUntil != "":
+			fmt.Println("❌ Error: use only one of --for and --until")
+			return
+		case queuePauseFor != "":
+			d, err := time.ParseDuration(queuePauseFor)
+			if err != nil || d < time.Second {
+				fmt.Printf("❌ Error: invalid --for %q (use e.g. 30m, 2h)\n", queuePauseFor)
+				return
+			}
+			resumeAt = formatTime(time.Now().Add(d))
+		case queuePauseUntil != "":
+			t, err := time.Parse(time.RFC3339, queuePauseUntil)
+			if err != nil {
+				fmt.Printf("❌ Error: invalid --until %q (use RFC3339, e.g. 2026-11-01T03:00:00Z)\n", queuePauseUntil)
+				return
+			}
+			if !t.After(time.Now()) {
+				fmt.Println("❌ Error: --until is in the past")
+				return
+			}
+			resumeAt = formatTime(t)
+		}
+
+		st, err := openStore()
+		if err != nil {
+			fmt.Println("DB error:", err)
+			return
+		}
+		defer st.Close()
+
+		// Pausing a paused queue replaces the earlier pause
+		err = st.PauseQueue(store.QueuePause{
+			Queue:    queue,
+			PausedBy: client.CurrentUser(),
+			Reason:   queuePauseReason,
+			ResumeAt: resumeAt,
+		})
+		if err != nil {
+			fmt.Println("Error pausing queue:", err)
+			return
+		}
+
+		if resumeAt != "" {
+			fmt.Printf("⏸️  Queue %s paused until %s\n", queue, resumeAt)
+		} else {
+			fmt.Printf("⏸️  Queue %s paused\n", queue)
+		}
+	},
+}
+
+var queueResumeCmd = &cobra.Command{
+	Use:   "resume <name>",
+	Short: "Let workers take jobs from a paused queue again",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		st, err := openStore()
+		if err != nil {
+			fmt.Println("DB error:", err)
+			return
+		}
+		defer st.Close()
+
+		// A pause that already ran out counts as not paused
+		resumed, err := st.ResumeQueue(args[0])
+		if err != nil {
+			fmt.Println("Error resuming queue:", err)
+			return
+		}
+		if !resumed {
+			fmt.Printf("Queue %s is not paused\n", args[0])
+			return
+		}
+
+		fmt.Printf("▶️  Queue %s resumed\n", args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(queueCmd)
+	queueCmd.AddCommand(queuePauseCmd)
+	queueCmd.AddCommand(queueResumeCmd)
+
+	queuePauseCmd.Flags().StringVar(&queuePauseFor, "for", "", "Resume the queue automatically after this long (e.g. 30m, 2h)")
+	queuePauseCmd.Flags().StringVar(&queuePauseUntil, "until", "", "Resume the queue automatically at this time (RFC3339)")
+	queuePauseCmd.Flags().StringVarP(&queuePauseReason, "reason", "r", "", "Why the queue is paused (shown by status)")
+}
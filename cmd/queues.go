@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"queuectl/internal/store"
+	"queuectl/pkg/client"
+	"queuectl/pkg/worker"
+)
+
+// parseQueueSubscriptions parses a comma-separated list of queue names,
+// each optionally followed by ":weight" (default 1).
+func parseQueueSubscriptions(value string) ([]worker.Queue, error) {
+	var subs []worker.Queue
+	seen := map[string]bool{}
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		sub := worker.Queue{Name: part, Weight: 1}
+		if name, weight, ok := strings.Cut(part, ":"); ok {
+			w, err := strconv.Atoi(weight)
+			if err != nil || w < 1 {
+				return nil, fmt.Errorf("invalid weight in %q (must be a number >= 1)", part)
+			}
+			sub = worker.Queue{Name: name, Weight: w}
+		}
+
+		if err := client.ValidateQueueName(sub.Name); err != nil {
+			return nil, err
+		}
+		if seen[sub.Name] {
+			return nil, fmt.Errorf("queue %q listed twice", sub.Name)
+		}
+		seen[sub.Name] = true
+		subs = append(subs, sub)
+	}
+
+	return subs, nil
+}
+
+// queuePause is how status, list and show output a store.QueuePause.
+type queuePause struct {
+	Paused_at string `json:"paused_at" yaml:"paused_at"`
+	Paused_by string `json:"paused_by,omitempty" yaml:"paused_by,omitempty"`
+	Reason    string `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Resume_at string `json:"resume_at,omitempty" yaml:"resume_at,omitempty"`
+}
+
+// describe says how long the pause lasts and who set it, e.g. for status.
+func (p queuePause) describe() string {
+	s := "⏸️  PAUSED"
+	if p.Resume_at != "" {
+		s += " until " + p.Resume_at
+	}
+	s += " (since " + p.Paused_at
+	if p.Paused_by != "" {
+		s += " by " + p.Paused_by
+	}
+	if p.Reason != "" {
+		s += ": " + p.Reason
+	}
+	return s + ")"
+}
+
+// loadPausedQueues returns the queues paused now. Pauses whose auto-resume
+// time has passed are left out.
+func loadPausedQueues(st store.Store) (map[string]queuePause, error) {
+	pauses, err := st.PausedQueues()
+	if err != nil {
+		return nil, err
+	}
+
+	paused := map[string]queuePause{}
+	for queue, p := range pauses {
+		paused[queue] = queuePause{
+			Paused_at: p.PausedAt,
+			Paused_by: p.PausedBy,
+			Reason:    p.Reason,
+			Resume_at: p.ResumeAt,
+		}
+	}
+	return paused, nil
+}
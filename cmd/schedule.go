@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"queuectl/internal/store"
+	"queuectl/pkg/client"
+
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cobra"
+)
+
+var scheduleCron string
+var scheduleCommand string
+var scheduleMaxRetries int
+var scheduleTimeout string
+var scheduleCatchUp string
+var scheduleQueue string
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Manage recurring jobs",
+	Long: `Recurring jobs are enqueued by running workers (or by "queuectl scheduler")
+whenever their cron expression comes due. Cron expressions use the usual
+five fields (minute hour day-of-month month day-of-week) in UTC, or
+descriptors such as @hourly and @daily.`,
+}
+
+var scheduleAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add a recurring job",
+	Long: `Add a recurring job.
+
+Catch-up policies decide what happens to occurrences missed while no
+worker or scheduler was running:
+  once - enqueue a single job for all missed occurrences (default)
+  all  - enqueue one job per missed occurrence
+  skip - drop missed occurrences and wait for the next one`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		if scheduleCron == "" || scheduleCommand == "" {
+			fmt.Println("❌ Error: both --cron and -c are required")
+			return
+		}
+
+		sched, err := cron.ParseStandard(scheduleCron)
+		if err != nil {
+			fmt.Printf("❌ Error: invalid cron expression %q: %v\n", scheduleCron, err)
+			return
+		}
+
+		if scheduleCatchUp != "once" && scheduleCatchUp != "all" && scheduleCatchUp != "skip" {
+			fmt.Println("❌ Error: --catch-up must be one of: once, all, skip")
+			return
+		}
+
+		// Reuse job validation for the per-run settings
+		spec := client.JobSpec{Queue: scheduleQueue, Command: scheduleCommand, Timeout: scheduleTimeout}
+		if cmd.Flags().Changed("max-retries") {
+			spec.Max_retries = &scheduleMaxRetries
+		}
+		if err := spec.Validate(); err != nil {
+			fmt.Println("❌ Error:", err)
+			return
+		}
+		if spec.Queue == "" {
+			spec.Queue = client.DefaultQueue
+		}
+		var timeoutSeconds sql.NullInt64
+		if spec.Timeout != "" {
+			timeout, _ := time.ParseDuration(spec.Timeout)
+			timeoutSeconds = sql.NullInt64{Int64: int64(timeout / time.Second), Valid: true}
+		}
+
+		var maxRetries sql.NullInt64
+		if spec.Max_retries != nil {
+			maxRetries = sql.NullInt64{Int64: int64(*spec.Max_retries), Valid: true}
+		}
+
+		st, err := openStore()
+		if err != nil {
+			fmt.Println("DB error:", err)
+			return
+		}
+		defer st.Close()
+
+		scheduleId := client.NewJobID()
+		nextRun := formatTime(sched.Next(time.Now().UTC()))
+
+		err = st.AddSchedule(store.Schedule{
+			Id:             scheduleId,
+			Queue:          spec.Queue,
+			Cron:           scheduleCron,
+			Command:        scheduleCommand,
+			MaxRetries:     maxRetries,
+			TimeoutSeconds: timeoutSeconds,
+			CatchUp:        scheduleCatchUp,
+			NextRunAt:      nextRun,
+		})
+		if err != nil {
+			fmt.Println("Error saving schedule:", err)
+			return
+		}
+
+		fmt.Println("✅ Schedule added with ID:", scheduleId)
+		fmt.Println("   First run at:", nextRun)
+	},
+}
+
+var scheduleListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List recurring jobs",
+	Run: func(cmd *cobra.Command, args []string) {
+
+		st, err := openStore()
+		if err != nil {
+			fmt.Println("DB error:", err)
+			return
+		}
+		defer st.Close()
+
+		schedules, err := st.Schedules()
+		if err != nil {
+			fmt.Println("Query error:", err)
+			return
+		}
+
+		fmt.Println("\n===== SCHEDULES =====")
+
+		for _, s := range schedules {
+			state := "active"
+			if s.Paused {
+				state = "paused"
+			}
+			lastRun := "never"
+			if s.LastRunAt.Valid {
+				lastRun = s.LastRunAt.String
+			}
+
+			fmt.Printf(`
+ID: %s
+Queue: %s
+Cron: %s
+Command: %s
+State: %s
+Catch-up: %s
+Next Run At: %s
+Last Run At: %s
+`,
+				s.Id, s.Queue, s.Cron, s.Command, state, s.CatchUp, s.NextRunAt, lastRun)
+		}
+
+		if len(schedules) == 0 {
+			fmt.Println("No schedules.")
+		}
+
+		fmt.Print("\n=====================\n\n")
+	},
+}
+
+// setSchedulePaused pauses or resumes a schedule. Resuming starts from the
+// next occurrence after now, so nothing missed while paused is enqueued.
+func setSchedulePaused(scheduleId string, paused bool) {
+	st, err := openStore()
+	if err != nil {
+		fmt.Println("DB error:", err)
+		return
+	}
+	defer st.Close()
+
+	s, err := st.GetSchedule(scheduleId)
+	if errors.Is(err, sql.ErrNoRows) {
+		fmt.Println("No such schedule:", scheduleId)
+		return
+	}
+	if err != nil {
+		fmt.Println("Error reading schedule:", err)
+		return
+	}
+
+	if paused {
+		_, err = st.PauseSchedule(scheduleId)
+	} else {
+		sched, parseErr := cron.ParseStandard(s.Cron)
+		if parseErr != nil {
+			fmt.Println("Error parsing cron expression:", parseErr)
+			return
+		}
+		_, err = st.ResumeSchedule(scheduleId, formatTime(sched.Next(time.Now().UTC())))
+	}
+	if err != nil {
+		fmt.Println("Error updating schedule:", err)
+		return
+	}
+
+	if paused {
+		fmt.Printf("⏸️  Schedule %s paused\n", scheduleId)
+	} else {
+		fmt.Printf("▶️  Schedule %s resumed\n", scheduleId)
+	}
+}
+
+var schedulePauseCmd = &cobra.Command{
+	Use:   "pause <scheduleId>",
+	Short: "Stop a recurring job from enqueuing new runs",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		setSchedulePaused(args[0], true)
+	},
+}
+
+var scheduleResumeCmd = &cobra.Command{
+	Use:   "resume <scheduleId>",
+	Short: "Resume a paused recurring job from its next occurrence",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		setSchedulePaused(args[0], false)
+	},
+}
+
+var scheduleDeleteCmd = &cobra.Command{
+	Use:   "delete <scheduleId>",
+	Short: "Delete a recurring job (jobs it already enqueued are kept)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		st, err := openStore()
+		if err != nil {
+			fmt.Println("DB error:", err)
+			return
+		}
+		defer st.Close()
+
+		deleted, err := st.DeleteSchedule(args[0])
+		if err != nil {
+			fmt.Println("Error deleting schedule:", err)
+			return
+		}
+		if !deleted {
+			fmt.Println("No such schedule:", args[0])
+			return
+		}
+
+		fmt.Printf("🗑️  Schedule %s deleted\n", args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scheduleCmd)
+	scheduleCmd.AddCommand(scheduleAddCmd)
+	scheduleCmd.AddCommand(scheduleListCmd)
+	scheduleCmd.AddCommand(schedulePauseCmd)
+	scheduleCmd.AddCommand(scheduleResumeCmd)
+	scheduleCmd.AddCommand(scheduleDeleteCmd)
+
+	scheduleAddCmd.Flags().StringVar(&scheduleCron, "cron", "", `Cron expression, e.g. "*/5 * * * *" (UTC)`)
+	scheduleAddCmd.Flags().StringVarP(&scheduleCommand, "command", "c", "", "Command for each run")
+	scheduleAddCmd.Flags().StringVarP(&scheduleQueue, "queue", "q", "", "Queue to add each run to (default: \"default\")")
+	scheduleAddCmd.Flags().IntVar(&scheduleMaxRetries, "max-retries", 0, "Attempts per run before it moves to the DLQ (default: config max-retries)")
+	scheduleAddCmd.Flags().StringVar(&scheduleTimeout, "timeout", "", "Kill a run if it takes longer than this (e.g. 30s, 5m)")
+	scheduleAddCmd.Flags().StringVar(&scheduleCatchUp, "catch-up", "once", "What to do with missed runs: once, all or skip")
+}
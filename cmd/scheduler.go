@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"queuectl/internal/store"
+	"queuectl/pkg/client"
+
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cobra"
+)
+
+// maxCatchUpRuns bounds how many missed occurrences a schedule with
+// catch-up "all" enqueues in one go, e.g. after days of downtime.
+const maxCatchUpRuns = 1000
+
+var schedulerInterval int
+var schedulerVerbose bool
+
+// materializeSchedules turns every due occurrence of every active schedule
+// into a pending job and returns how many jobs were enqueued. It is safe to
+// run from several processes at once; see materializeSchedule.
+func materializeSchedules(st store.Store) (int, error) {
+	now := time.Now()
+
+	due, err := st.DueSchedules(formatTime(now))
+	if err != nil {
+		return 0, err
+	}
+
+	enqueued := 0
+	for _, s := range due {
+		cfgMaxRetries, _ := resolveConfig(st, s.Queue, "max-retries")
+		n, err := materializeSchedule(st, s, now, cfgMaxRetries)
+		if err != nil {
+			return enqueued, fmt.Errorf("schedule %s: %w", s.Id, err)
+		}
+		enqueued += n
+	}
+
+	return enqueued, nil
+}
+
+// materializeSchedule advances one schedule past now and enqueues jobs for
+// the occurrences it stepped over, as allowed by its catch-up policy:
+//
+//	all  - one job per missed occurrence (at most maxCatchUpRuns)
+//	once - a single job no matter how many occurrences were missed
+//	skip - a job only for an occurrence less than a minute old
+//
+// Advancing Next_run_at is a compare-and-swap in the same transaction as
+// the inserts, so when several processes race only one of them enqueues.
+func materializeSchedule(st store.Store, s store.Schedule, now time.Time, cfgMaxRetries int) (int, error) {
+	sched, err := cron.ParseStandard(s.Cron)
+	if err != nil {
+		return 0, err
+	}
+
+	next, err := time.Parse(time.RFC3339, s.NextRunAt)
+	if err != nil {
+		return 0, err
+	}
+
+	var missed []time.Time
+	for !next.After(now) {
+		if len(missed) < maxCatchUpRuns {
+			missed = append(missed, next)
+		}
+		next = sched.Next(next)
+	}
+	if len(missed) == 0 {
+		return 0, nil
+	}
+
+	var occurrences []time.Time
+	switch s.CatchUp {
+	case "all":
+		occurrences = missed
+	case "skip":
+		if last := missed[len(missed)-1]; now.Sub(last) < time.Minute {
+			occurrences = []time.Time{last}
+		}
+	default:
+		occurrences = missed[len(missed)-1:]
+	}
+
+	maxRetries := cfgMaxRetries
+	if s.MaxRetries.Valid {
+		maxRetries = int(s.MaxRetries.Int64)
+	}
+
+	var jobs []store.NewJob
+	for _, occurrence := range occurrences {
+		created := nowTime()
+		jobs = append(jobs, store.NewJob{
+			Id:             client.NewJobID(),
+			Queue:          s.Queue,
+			Command:        s.Command,
+			State:          "pending",
+			MaxRetries:     maxRetries,
+			CreatedAt:      created,
+			UpdatedAt:      created,
+			NextRunAt:      sql.NullString{String: created, Valid: true},
+			TimeoutSeconds: s.TimeoutSeconds,
+			ScheduleId:     sql.NullString{String: s.Id, Valid: true},
+			ScheduledFor:   sql.NullString{String: formatTime(occurrence), Valid: true},
+			Actor:          client.CurrentUser(),
+		})
+	}
+
+	advanced, err := st.AdvanceSchedule(s, formatTime(next), lastOccurrence(occurrences), jobs)
+	if err != nil {
+		return 0, err
+	}
+	if !advanced {
+		// Another process got here first
+		return 0, nil
+	}
+	return len(occurrences), nil
+}
+
+func lastOccurrence(occurrences []time.Time) sql.NullString {
+	if len(occurrences) == 0 {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: formatTime(occurrences[len(occurrences)-1]), Valid: true}
+}
+
+// runScheduler materializes due schedules every interval until stop is
+// closed.
+func runScheduler(st store.Store, interval time.Duration, verbose bool, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := materializeSchedules(st)
+		if err != nil {
+			fmt.Println("Scheduler error:", err)
+		} else if verbose && n > 0 {
+			fmt.Printf("[scheduler] 📅 Enqueued %d scheduled job(s)\n", n)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+var schedulerCmd = &cobra.Command{
+	Use:   "scheduler",
+	Short: "Enqueue jobs from recurring schedules without running any workers",
+	Long: `Runs only the schedule loop that workers also run in the background.
+Useful when workers are started with --no-scheduler, or on a machine
+that should create jobs but not execute them. Any number of schedulers
+and workers can run at once; each occurrence is enqueued exactly once.`,
+	Run: func(cmd *cobra.Command, args []string) {
+
+		st, err := openStore()
+		if err != nil {
+			fmt.Println("DB error:", err)
+			return
+		}
+		defer st.Close()
+
+		fmt.Println("Scheduler started")
+		runScheduler(st, time.Duration(schedulerInterval)*time.Second, schedulerVerbose, nil)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(schedulerCmd)
+	schedulerCmd.Flags().IntVarP(&schedulerInterval, "interval", "i", 5, "Seconds between checks for due schedules")
+	schedulerCmd.Flags().BoolVarP(&schedulerVerbose, "verbose", "v", false, "Verbose logs")
+}
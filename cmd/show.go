@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"queuectl/internal/store"
+	"queuectl/pkg/client"
+
+	"github.com/spf13/cobra"
+)
+
+// excerptLines is how many trailing lines of each output show prints per
+// attempt; "queuectl logs" has the rest.
+const excerptLines = 3
+
+// jobDetail is everything "queuectl show" knows about one job.
+type jobDetail struct {
+	Job             jobInfo       `json:"job" yaml:"job"`
+	Next_attempt_at string        `json:"next_attempt_at,omitempty" yaml:"next_attempt_at,omitempty"`
+	Attempts        []attemptInfo `json:"attempts" yaml:"attempts"`
+	Events          []jobEvent    `json:"events" yaml:"events"`
+}
+
+type attemptInfo struct {
+	Attempt        int    `json:"attempt" yaml:"attempt"`
+	Worker_id      string `json:"worker_id,omitempty" yaml:"worker_id,omitempty"`
+	Started_at     string `json:"started_at,omitempty" yaml:"started_at,omitempty"`
+	Finished_at    string `json:"finished_at,omitempty" yaml:"finished_at,omitempty"`
+	Duration       string `json:"duration,omitempty" yaml:"duration,omitempty"`
+	Exit_code      *int   `json:"exit_code" yaml:"exit_code"`
+	Signal         string `json:"signal,omitempty" yaml:"signal,omitempty"`
+	Stdout_excerpt string `json:"stdout_excerpt,omitempty" yaml:"stdout_excerpt,omitempty"`
+	Stderr_excerpt string `json:"stderr_excerpt,omitempty" yaml:"stderr_excerpt,omitempty"`
+}
+
+// excerpt returns the last n lines of output.
+func excerpt(output string, n int) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func newAttemptInfo(a store.Attempt) attemptInfo {
+	info := attemptInfo{
+		Attempt:        a.Attempt,
+		Worker_id:      a.WorkerId.String,
+		Started_at:     a.StartedAt.String,
+		Finished_at:    a.FinishedAt.String,
+		Signal:         a.Signal.String,
+		Stdout_excerpt: excerpt(a.Stdout.String, excerptLines),
+		Stderr_excerpt: excerpt(a.Stderr.String, excerptLines),
+	}
+	if a.ExitCode.Valid {
+		code := int(a.ExitCode.Int64)
+		info.Exit_code = &code
+	}
+	started, err1 := time.Parse(time.RFC3339, a.StartedAt.String)
+	finished, err2 := time.Parse(time.RFC3339, a.FinishedAt.String)
+	if err1 == nil && err2 == nil {
+		info.Duration = finished.Sub(started).String()
+	}
+	return info
+}
+
+func loadJobDetail(ctx context.Context, st store.Store, jobId string) (jobDetail, error) {
+	var detail jobDetail
+
+	stored, err := newClient(st).Get(ctx, jobId)
+	if err != nil {
+		return detail, err
+	}
+	job := newJobInfo(stored)
+	paused, err := loadPausedQueues(st)
+	if err != nil {
+		return detail, err
+	}
+	_, job.Queue_paused = paused[job.Queue]
+	detail.Job = job
+
+	// A pending job that isn't due yet is waiting for a retry or its run_at
+	if job.State == "pending" && job.Next_run_at > nowTime() {
+		detail.Next_attempt_at = job.Next_run_at
+	}
+
+	logs, err := st.JobAttempts(jobId, 0)
+	if err != nil {
+		return detail, err
+	}
+	detail.Attempts = []attemptInfo{}
+	for _, a := range logs {
+		detail.Attempts = append(detail.Attempts, newAttemptInfo(a))
+	}
+
+	detail.Events, err = fetchJobEvents(st, jobId)
+	return detail, err
+}
+
+func printJobDetail(d jobDetail) {
+	job := d.Job
+
+	fmt.Printf("\n===== JOB %s =====\n", job.Id)
+
+	queue := job.Queue
+	if job.Queue_paused {
+		queue += "   ⏸️  PAUSED"
+	}
+
+	fmt.Printf("Queue:          %s\n", queue)
+	if job.Command != "" || job.Type == "" {
+		fmt.Printf("Command:        %s\n", job.Command)
+	}
+	if job.Type != "" {
+		fmt.Printf("Type:           %s\n", job.Type)
+	}
+	if job.Payload != nil {
+		payload, _ := json.Marshal(job.Payload)
+		fmt.Printf("Payload:        %s\n", payload)
+	}
+	fmt.Printf("State:          %s\n", job.State)
+	fmt.Printf("Attempts:       %d / %d\n", job.Attempts, *job.Max_retries)
+	fmt.Printf("Priority:       %d\n", *job.Priority)
+
+	for _, field := range []struct{ label, value string }{
+		{"Timeout", job.Timeout},
+		{"Backoff", job.Backoff},
+		{"Backoff Base", job.Backoff_base},
+		{"Backoff Max", job.Backoff_max},
+		{"Retry On", client.FormatExitCodes(job.Retry_on)},
+		{"Fail Fast On", client.FormatExitCodes(job.Fail_fast_on)},
+		{"Tags", strings.Join(job.Tags, ", ")},
+		{"Worker", job.Worker_id},
+		{"Failure Reason", job.Failure_reason},
+		{"Cancelled By", job.Cancelled_by},
+		{"Cancel Reason", job.Cancel_reason},
+	} {
+		if field.value != "" {
+			fmt.Printf("%-15s %s\n", field.label+":", field.value)
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(job.Env)) {
+		fmt.Printf("%-15s %s=%s\n", "Env:", key, job.Env[key])
+	}
+
+	fmt.Printf("Created At:     %s\n", job.Created_at)
+	fmt.Printf("Updated At:     %s\n", job.Updated_at)
+
+	if d.Next_attempt_at != "" {
+		next := d.Next_attempt_at
+		if t, err := time.Parse(time.RFC3339, next); err == nil {
+			next += fmt.Sprintf(" (in %v)", time.Until(t).Round(time.Second))
+		}
+		fmt.Printf("Next Attempt:   %s\n", next)
+	}
+
+	fmt.Println("\n----- Attempts -----")
+	for _, a := range d.Attempts {
+		result := "running"
+		switch {
+		case a.Signal != "":
+			result = "killed by " + a.Signal
+		case a.Exit_code != nil:
+			result = fmt.Sprintf("exit code %d", *a.Exit_code)
+		case a.Finished_at != "":
+			result = "exit code unknown (worker lost)"
+		}
+
+		took := ""
+		if a.Duration != "" {
+			took = " (" + a.Duration + ")"
+		}
+
+		fmt.Printf("#%d  worker %s  %s%s  %s\n", a.Attempt, a.Worker_id, a.Started_at, took, result)
+		for _, out := range []struct{ name, text string }{{"stdout", a.Stdout_excerpt}, {"stderr", a.Stderr_excerpt}} {
+			if out.text == "" {
+				continue
+			}
+			for _, line := range strings.Split(out.text, "\n") {
+				fmt.Printf("    [%s] %s\n", out.name, line)
+			}
+		}
+	}
+	if len(d.Attempts) == 0 {
+		fmt.Println("No attempts yet.")
+	} else {
+		fmt.Printf("(Full output: queuectl logs %s)\n", job.Id)
+	}
+
+	fmt.Println("\n----- History -----")
+	for _, e := range d.Events {
+		transition := ""
+		if e.To_state != "" {
+			transition = e.From_state + " → " + e.To_state
+			if e.From_state == "" {
+				transition = "→ " + e.To_state
+			}
+		}
+
+		var extra []string
+		if e.Attempt > 0 {
+			extra = append(extra, fmt.Sprintf("attempt %d", e.Attempt))
+		}
+		if e.Worker_id != "" {
+			extra = append(extra, "worker "+e.Worker_id)
+		}
+		if e.Actor != "" {
+			extra = append(extra, "by "+e.Actor)
+		}
+		if e.Detail != "" {
+			extra = append(extra, e.Detail)
+		}
+
+		fmt.Printf("%s  %-16s %-24s %s\n", e.At, e.Event, transition, strings.Join(extra, ", "))
+	}
+	if len(d.Events) == 0 {
+		fmt.Println("No history recorded (the job predates it).")
+	}
+
+	fmt.Println("===========================")
+}
+
+var showCmd = &cobra.Command{
+	Use:   "show <jobId>",
+	Short: "Show a job's spec, attempts and full history",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+
+		st, err := openStore()
+		if err != nil {
+			fmt.Println("DB error:", err)
+			return
+		}
+		defer st.Close()
+
+		detail, err := loadJobDetail(cmd.Context(), st, args[0])
+		if errors.Is(err, client.ErrNotFound) {
+			fmt.Println("No such job:", args[0])
+			return
+		}
+		if err != nil {
+			fmt.Println("Error reading job:", err)
+			return
+		}
+
+		if machineOutput() {
+			printOutputOrExit(detail)
+			return
+		}
+
+		printJobDetail(detail)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(showCmd)
+}
@@ -1,94 +1,209 @@
 package cmd
 
 import (
-	"database/sql"
 	"fmt"
-	"os"
+	"maps"
+	"slices"
+	"time"
+
+	"queuectl/internal/store"
 
 	"github.com/spf13/cobra"
-	_ "modernc.org/sqlite"
 )
 
+// statusReport is what "queuectl status" shows.
+type statusReport struct {
+	Jobs             []jobState       `json:"jobs" yaml:"jobs"`
+	Queues           []queueStatus    `json:"queues" yaml:"queues"`
+	Workers          []workerInfo     `json:"workers" yaml:"workers"`
+	Control_messages []controlMessage `json:"control_messages" yaml:"control_messages"`
+}
+
+type jobState struct {
+	Id    string `json:"id" yaml:"id"`
+	State string `json:"state" yaml:"state"`
+}
+
+// queueStatus counts the jobs of a queue by state.
+type queueStatus struct {
+	Queue  string         `json:"queue" yaml:"queue"`
+	Counts map[string]int `json:"counts" yaml:"counts"`
+	Paused *queuePause    `json:"paused,omitempty" yaml:"paused,omitempty"`
+}
+
+type workerInfo struct {
+	Worker_id      string `json:"worker_id" yaml:"worker_id"`
+	Host           string `json:"host" yaml:"host"`
+	Pid            int    `json:"pid" yaml:"pid"`
+	State          string `json:"state" yaml:"state"`
+	Started_at     string `json:"started_at" yaml:"started_at"`
+	Last_heartbeat string `json:"last_heartbeat" yaml:"last_heartbeat"`
+	Queues         string `json:"queues,omitempty" yaml:"queues,omitempty"`
+}
+
+type controlMessage struct {
+	Worker_id  string `json:"worker_id" yaml:"worker_id"`
+	Command    string `json:"command" yaml:"command"`
+	Created_at string `json:"created_at" yaml:"created_at"`
+	Acked_at   string `json:"acked_at,omitempty" yaml:"acked_at,omitempty"`
+}
+
+// loadStatus gathers everything status shows. Control messages are those
+// still waiting for their worker and those handled in the last 10 minutes.
+func loadStatus(st store.Store) (statusReport, error) {
+	report := statusReport{
+		Jobs:             []jobState{},
+		Queues:           []queueStatus{},
+		Workers:          []workerInfo{},
+		Control_messages: []controlMessage{},
+	}
+
+	jobs, _, err := st.ListJobs(store.ListFilter{Sort: "created_at", Desc: true})
+	if err != nil {
+		return report, fmt.Errorf("fetching jobs: %w", err)
+	}
+	for _, job := range jobs {
+		report.Jobs = append(report.Jobs, jobState{Id: job.Id, State: job.State})
+	}
+
+	paused, err := loadPausedQueues(st)
+	if err != nil {
+		return report, fmt.Errorf("fetching paused queues: %w", err)
+	}
+
+	counts, err := st.QueueCounts()
+	if err != nil {
+		return report, fmt.Errorf("fetching queues: %w", err)
+	}
+	for _, c := range counts {
+		if n := len(report.Queues); n == 0 || report.Queues[n-1].Queue != c.Queue {
+			q := queueStatus{Queue: c.Queue, Counts: map[string]int{}}
+			if p, ok := paused[c.Queue]; ok {
+				q.Paused = &p
+				delete(paused, c.Queue)
+			}
+			report.Queues = append(report.Queues, q)
+		}
+		report.Queues[len(report.Queues)-1].Counts[c.State] = c.Count
+	}
+
+	// Paused queues without any jobs yet
+	for _, Queue := range slices.Sorted(maps.Keys(paused)) {
+		p := paused[Queue]
+		report.Queues = append(report.Queues, queueStatus{Queue: Queue, Counts: map[string]int{}, Paused: &p})
+	}
+
+	workers, err := st.Workers()
+	if err != nil {
+		return report, fmt.Errorf("fetching workers: %w", err)
+	}
+	for _, w := range workers {
+		report.Workers = append(report.Workers, workerInfo{
+			Worker_id:      w.Id,
+			Host:           w.Host,
+			Pid:            w.Pid,
+			State:          w.State,
+			Started_at:     w.StartedAt,
+			Last_heartbeat: w.LastHeartbeat,
+			Queues:         w.Queues,
+		})
+	}
+
+	messages, err := st.SentWorkerCommands(formatTime(time.Now().Add(-10*time.Minute)), 20)
+	if err != nil {
+		return report, fmt.Errorf("fetching control messages: %w", err)
+	}
+	for _, m := range messages {
+		report.Control_messages = append(report.Control_messages, controlMessage{
+			Worker_id:  m.WorkerId,
+			Command:    m.Command,
+			Created_at: m.CreatedAt,
+			Acked_at:   m.AckedAt,
+		})
+	}
+
+	return report, nil
+}
+
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show all job states and active workers",
 	Run: func(cmd *cobra.Command, args []string) {
 
-		// Ensure database folder exists
-		os.MkdirAll("data", 0755)
-
 		// Connect to database
-		db, err := sql.Open("sqlite", "data/queue.db")
+		st, err := openStore()
 		if err != nil {
 			fmt.Println("Database error:", err)
 			return
 		}
-		defer db.Close()
-
-	
-		fmt.Println("\n===== JOB STATES =====")
+		defer st.Close()
 
-		jobRows, err := db.Query(`SELECT Id, State FROM jobs ORDER BY Created_at DESC`)
+		report, err := loadStatus(st)
 		if err != nil {
-			fmt.Println("Error fetching jobs:", err)
+			fmt.Println("Error", err)
 			return
 		}
-		defer jobRows.Close()
 
-		hasJobs := false
-
-		for jobRows.Next() {
-			hasJobs = true
-			var Id string
-			var State string
+		if machineOutput() {
+			printOutputOrExit(report)
+			return
+		}
 
-			err = jobRows.Scan(&Id, &State)
-			if err != nil {
-				fmt.Println("Error reading job:", err)
-				continue
-			}
+		fmt.Println("\n===== JOB STATES =====")
 
-			fmt.Printf("ID: %s   State: %s\n", Id, State)
+		for _, job := range report.Jobs {
+			fmt.Printf("ID: %s   State: %s\n", job.Id, job.State)
 		}
 
-		if !hasJobs {
+		if len(report.Jobs) == 0 {
 			fmt.Println("No jobs found.")
 		}
 
-		fmt.Println("\n===== ACTIVE WORKERS =====")
+		fmt.Println("\n===== QUEUES =====")
 
-		workerRows, err := db.Query(`
-			SELECT WorkerId, Last_heartbeat 
-			FROM workers 
-			ORDER BY Last_heartbeat DESC
-		`)
-		if err != nil {
-			fmt.Println("Error fetching workers:", err)
-			return
+		for _, q := range report.Queues {
+			if q.Paused != nil {
+				fmt.Printf("Queue: %s   %s\n", q.Queue, q.Paused.describe())
+			} else {
+				fmt.Printf("Queue: %s\n", q.Queue)
+			}
+			for _, State := range slices.Sorted(maps.Keys(q.Counts)) {
+				fmt.Printf("   %-10s %d\n", State, q.Counts[State])
+			}
 		}
-		defer workerRows.Close()
-
-		hasWorkers := false
 
-		for workerRows.Next() {
-			hasWorkers = true
+		if len(report.Queues) == 0 {
+			fmt.Println("No queues yet.")
+		}
 
-			var WorkerId string
-			var LastHeartbeat string
+		fmt.Println("\n===== ACTIVE WORKERS =====")
 
-			err = workerRows.Scan(&WorkerId, &LastHeartbeat)
-			if err != nil {
-				fmt.Println("Error reading worker:", err)
-				continue
+		for _, w := range report.Workers {
+			queues := "all"
+			if w.Queues != "" {
+				queues = w.Queues
 			}
 
-			fmt.Printf("Worker: %s   Heartbeat: %s\n", WorkerId, LastHeartbeat)
+			fmt.Printf("Worker: %s   Host: %s   State: %s   Heartbeat: %s   Queues: %s\n",
+				w.Worker_id, w.Host, w.State, w.Last_heartbeat, queues)
 		}
 
-		if !hasWorkers {
+		if len(report.Workers) == 0 {
 			fmt.Println("No active workers.")
 		}
 
+		if len(report.Control_messages) > 0 {
+			fmt.Println("\n===== CONTROL MESSAGES =====")
+		}
+
+		for _, m := range report.Control_messages {
+			ack := "⏳ waiting"
+			if m.Acked_at != "" {
+				ack = "✅ acknowledged " + m.Acked_at
+			}
+			fmt.Printf("%s → %s   Sent: %s   %s\n", m.Command, m.Worker_id, m.Created_at, ack)
+		}
+
 		fmt.Println("===========================")
 	},
 }
@@ -0,0 +1,13 @@
+package cmd
+
+import "time"
+
+// Timestamps are stored as UTC RFC3339 text so that SQLite can compare
+// them as plain strings regardless of the local timezone.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func nowTime() string {
+	return formatTime(time.Now())
+}
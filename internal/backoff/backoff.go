@@ -0,0 +1,91 @@
+// Package backoff computes how long a failed job waits before its next
+// attempt, for the worker and for the previews "queuectl config" shows.
+package backoff
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Policy decides how long a failed job waits before its next attempt. Max
+// caps every delay.
+type Policy struct {
+	Strategy string
+	Base     time.Duration
+	Max      time.Duration
+}
+
+// Delay returns the wait before retrying after the given failed attempt
+// (1 for the first failure). prev is the delay used after the previous
+// failure, which decorrelated jitter grows from. Delays are whole seconds
+// because that's the precision Next_run_at is stored with.
+//
+//	fixed               - base every time
+//	linear              - base × attempt
+//	exponential         - base × 2^(attempt-1)
+//	full-jitter         - random between 0 and the exponential delay
+//	decorrelated-jitter - random between base and 3 × prev
+func (p Policy) Delay(attempt int, prev time.Duration) time.Duration {
+	var d time.Duration
+
+	switch p.Strategy {
+	case "fixed":
+		d = p.Base
+	case "linear":
+		d = p.Base * time.Duration(attempt)
+	case "full-jitter":
+		d = time.Duration(rand.Int63n(int64(p.exponential(attempt)) + 1))
+	case "decorrelated-jitter":
+		if prev < p.Base {
+			prev = p.Base
+		}
+		upper := min(prev*3, p.Max)
+		if upper < p.Base {
+			upper = p.Base
+		}
+		d = p.Base + time.Duration(rand.Int63n(int64(upper-p.Base)+1))
+	default:
+		d = p.exponential(attempt)
+	}
+
+	d = d.Round(time.Second)
+	if d > p.Max {
+		d = p.Max
+	}
+	return d
+}
+
+// exponential doubles base once per earlier attempt, stopping at Max so
+// large attempt numbers can't overflow.
+func (p Policy) exponential(attempt int) time.Duration {
+	d := p.Base
+	for i := 1; i < attempt && d < p.Max; i++ {
+		d *= 2
+	}
+	return min(d, p.Max)
+}
+
+// Preview describes the first few delays, e.g. for config get.
+func (p Policy) Preview() string {
+	switch p.Strategy {
+	case "decorrelated-jitter":
+		return fmt.Sprintf("random, from %v up to 3× the previous delay, at most %v", p.Base, p.Max)
+	case "full-jitter":
+		return fmt.Sprintf("random, up to %s, at most %v", p.steps(), p.Max)
+	}
+	return fmt.Sprintf("%s, at most %v", p.steps(), p.Max)
+}
+
+func (p Policy) steps() string {
+	var delays []string
+	for attempt := 1; attempt <= 4; attempt++ {
+		if p.Strategy == "full-jitter" {
+			delays = append(delays, p.exponential(attempt).String())
+		} else {
+			delays = append(delays, p.Delay(attempt, 0).String())
+		}
+	}
+	return strings.Join(delays, ", ") + "..."
+}
@@ -2,31 +2,83 @@ package db
 
 import (
 	"database/sql"
-	"log"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
 
-var DB *sql.DB
+// DefaultPath is where the database lives unless told otherwise.
+const DefaultPath = "data/queue.db"
 
-func Connect() error {
-	var err error
-	DB, err := sql.Open("sqlite", "./data/queue.db")
-	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
-		return err
+// DefaultBusyTimeout is how long a statement waits for a lock held by
+// another connection before failing with "database is locked".
+const DefaultBusyTimeout = 5 * time.Second
+
+// ErrNotExist is returned by Open for a database file that isn't there.
+var ErrNotExist = errors.New("database does not exist")
+
+type Options struct {
+	// BusyTimeout defaults to DefaultBusyTimeout
+	BusyTimeout time.Duration
+
+	// Create the file, and its directory, if it doesn't exist yet instead
+	// of failing with ErrNotExist
+	Create bool
+}
+
+// Open connects to the SQLite database at path. Every connection in the
+// pool uses WAL, so readers don't block the workers, the busy timeout and
+// foreign keys; the pragmas go in the DSN because a pragma run with Exec
+// only applies to whichever connection happened to run it.
+func Open(path string, opts Options) (*sql.DB, error) {
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && info.IsDir():
+		return nil, fmt.Errorf("database %s is a directory", path)
+	case errors.Is(err, fs.ErrNotExist) && opts.Create:
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+	case errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("%w: %s", ErrNotExist, path)
+	case err != nil:
+		return nil, err
 	}
 
-	err = DB.Ping()
+	busyTimeout := opts.BusyTimeout
+	if busyTimeout <= 0 {
+		busyTimeout = DefaultBusyTimeout
+	}
+
+	dsn := fmt.Sprintf("%s?_pragma=journal_mode(WAL)&_pragma=busy_timeout(%d)&_pragma=foreign_keys(1)",
+		path, busyTimeout.Milliseconds())
+
+	database, err := sql.Open("sqlite", dsn)
 	if err != nil {
-		log.Fatal("Failed to ping database:", err)
-		return err
+		return nil, err
 	}
 
-	log.Println("Connected to SQLite database successfully")
-	return nil
+	// sql.Open doesn't connect; find out now if path isn't a usable database
+	if err := database.Ping(); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("cannot open database %s: %w", path, err)
+	}
+	return database, nil
 }
 
-func Close() error {
-	return DB.Close()
+// Connect opens the database dsn names, a postgres:// URL or the path of
+// an SQLite file, and returns it with the schema for that kind of
+// database. It doesn't look at the schema; see Schema.Check.
+func Connect(dsn string, opts Options) (*sql.DB, *Schema, error) {
+	if IsPostgres(dsn) {
+		database, err := OpenPostgres(dsn)
+		return database, Postgres, err
+	}
+	database, err := Open(dsn, opts)
+	return database, SQLite, err
 }
@@ -1,19 +1,113 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func Migrate(db *sql.DB) error {
-	query := `
+// A Migration moves the schema from version Version-1 to Version. Each one
+// runs in its own transaction and is recorded in schema_migrations.
+//
+// Never change a migration once it has been released: databases that
+// already ran it won't run it again. Append a new one instead.
+type Migration struct {
+	Version int
+	Name    string
+	up      func(tx migrationTx) error
+}
+
+// Schema is the migrations of one kind of database together with the
+// statements that run them there. SQLite and PostgreSQL keep the same
+// version numbers, so "db status" reads the same on both.
+type Schema struct {
+	Name       string
+	migrations []Migration
+
+	// begin starts a migration's transaction and takes a lock no other
+	// migration can hold at the same time
+	begin []string
+
+	// versionTable counts the tables named schema_migrations
+	versionTable string
+
+	// recordVersion inserts a row into schema_migrations
+	recordVersion string
+}
+
+// SQLite is the schema of a database file.
+var SQLite = &Schema{
+	Name: "SQLite",
+	migrations: []Migration{
+		{Version: 1, Name: "create tables", up: createTables},
+		{Version: 2, Name: "create indexes", up: createIndexes},
+		{Version: 3, Name: "convert legacy jobs", up: convertLegacyJobs},
+		{Version: 4, Name: "add job types", up: addJobTypes},
+	},
+	// IMMEDIATE takes the write lock now; a deferred transaction could read
+	// the version and then find another process got there first
+	begin:         []string{`BEGIN IMMEDIATE`},
+	versionTable:  `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`,
+	recordVersion: `INSERT INTO schema_migrations (Version, Name, Applied_at) VALUES (?, ?, ?)`,
+}
+
+// ErrSchemaTooNew is returned for a database migrated by a newer queuectl,
+// which this one may misread or corrupt.
+var ErrSchemaTooNew = errors.New("database schema is newer than this queuectl")
+
+// ErrSchemaOutdated is returned by Check for a database that still has
+// migrations to run.
+var ErrSchemaOutdated = errors.New("database schema is out of date")
+
+// Migrations returns every migration this binary knows, in order.
+func (s *Schema) Migrations() []Migration {
+	return append([]Migration(nil), s.migrations...)
+}
+
+// LatestVersion is the schema version this binary expects.
+func (s *Schema) LatestVersion() int {
+	return s.migrations[len(s.migrations)-1].Version
+}
+
+// createTables is the schema as it was before migrations were versioned.
+// Those databases (version 0) were upgraded by ALTER statements whose
+// errors were ignored, so depending on the queuectl that created them any
+// of these tables may exist with only some of the columns. Add what's
+// missing to bring them all to the same place.
+func createTables(tx migrationTx) error {
+	_, err := tx.Exec(`
 	CREATE TABLE IF NOT EXISTS jobs (
 		Id TEXT PRIMARY KEY,
+		Queue TEXT NOT NULL DEFAULT 'default',
 		Command TEXT,
 		State TEXT,
 		Attempts INTEGER,
 		Max_retries INTEGER,
+		Priority INTEGER NOT NULL DEFAULT 0,
 		WorkerId TEXT,
+		Claim_token TEXT,
+		Lease_expires_at TEXT,
+		Timeout_seconds INTEGER,
+		Backoff_strategy TEXT,
+		Backoff_base_seconds INTEGER,
+		Backoff_max_seconds INTEGER,
+		Last_backoff_seconds INTEGER,
+		Retry_on TEXT,
+		Fail_fast_on TEXT,
+		Cancel_requested_at TEXT,
+		Cancelled_at TEXT,
+		Cancelled_by TEXT,
+		Cancel_reason TEXT,
+		Failure_reason TEXT,
+		Env TEXT,
+		Tags TEXT,
+		Schedule_id TEXT,
+		Scheduled_for TEXT,
 		Next_run_at TEXT DEFAULT CURRENT_TIMESTAMP,
 		Created_at TEXT,
 		Updated_at TEXT
@@ -22,29 +116,482 @@ func Migrate(db *sql.DB) error {
 	CREATE TABLE IF NOT EXISTS workers (
 		WorkerId TEXT PRIMARY KEY,
 		Started_at TEXT,
-		Last_heartbeat TEXT
+		Last_heartbeat TEXT,
+		Queues TEXT,
+		Host TEXT,
+		Pid INTEGER,
+		State TEXT NOT NULL DEFAULT 'running'
 	);
 
-	CREATE TABLE IF NOT EXISTS control (
-		Key TEXT PRIMARY KEY,
-		Value TEXT
+	CREATE TABLE IF NOT EXISTS worker_commands (
+		Id INTEGER PRIMARY KEY AUTOINCREMENT,
+		WorkerId TEXT NOT NULL,
+		Command TEXT NOT NULL,
+		Created_at TEXT,
+		Acked_at TEXT
 	);
 
 	CREATE TABLE IF NOT EXISTS config (
 		Key TEXT PRIMARY KEY,
 		Value TEXT
 	);
-	`
 
-	_, err := db.Exec(query)
+	CREATE TABLE IF NOT EXISTS queue_config (
+		Queue TEXT NOT NULL,
+		Key TEXT NOT NULL,
+		Value TEXT,
+		PRIMARY KEY (Queue, Key)
+	);
+
+	CREATE TABLE IF NOT EXISTS job_attempts (
+		Id INTEGER PRIMARY KEY AUTOINCREMENT,
+		JobId TEXT NOT NULL,
+		Attempt INTEGER NOT NULL,
+		WorkerId TEXT,
+		Started_at TEXT,
+		Finished_at TEXT,
+		Exit_code INTEGER,
+		Signal TEXT,
+		Stdout TEXT,
+		Stderr TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS job_events (
+		Id INTEGER PRIMARY KEY AUTOINCREMENT,
+		JobId TEXT NOT NULL,
+		At TEXT NOT NULL,
+		Event TEXT NOT NULL,
+		From_state TEXT,
+		To_state TEXT,
+		Attempt INTEGER,
+		WorkerId TEXT,
+		Actor TEXT,
+		Detail TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS schedules (
+		Id TEXT PRIMARY KEY,
+		Queue TEXT NOT NULL DEFAULT 'default',
+		Cron TEXT NOT NULL,
+		Command TEXT NOT NULL,
+		Max_retries INTEGER,
+		Timeout_seconds INTEGER,
+		Catch_up TEXT NOT NULL DEFAULT 'once',
+		Paused INTEGER NOT NULL DEFAULT 0,
+		Next_run_at TEXT NOT NULL,
+		Last_run_at TEXT,
+		Created_at TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS paused_queues (
+		Queue TEXT PRIMARY KEY,
+		Paused_at TEXT NOT NULL,
+		Paused_by TEXT,
+		Reason TEXT,
+		Resume_at TEXT
+	);
+	`)
 	if err != nil {
-		return fmt.Errorf("migration failed: %w", err)
+		return err
 	}
 
-	// Add missing columns to existing tables if needed
-	db.Exec(`ALTER TABLE jobs ADD COLUMN WorkerId TEXT`)
-	db.Exec(`ALTER TABLE jobs ADD COLUMN Next_run_at TEXT DEFAULT CURRENT_TIMESTAMP`)
+	// SQLite can't add a column with a non-constant default, so a jobs
+	// table without Next_run_at gets it without CURRENT_TIMESTAMP; the
+	// code always sets it anyway
+	err = addMissingColumns(tx, "jobs",
+		"WorkerId TEXT",
+		"Next_run_at TEXT",
+		"Claim_token TEXT",
+		"Lease_expires_at TEXT",
+		"Timeout_seconds INTEGER",
+		"Failure_reason TEXT",
+		"Env TEXT",
+		"Schedule_id TEXT",
+		"Scheduled_for TEXT",
+		"Priority INTEGER NOT NULL DEFAULT 0",
+		"Queue TEXT NOT NULL DEFAULT 'default'",
+		"Backoff_strategy TEXT",
+		"Backoff_base_seconds INTEGER",
+		"Backoff_max_seconds INTEGER",
+		"Last_backoff_seconds INTEGER",
+		"Retry_on TEXT",
+		"Cancel_requested_at TEXT",
+		"Cancelled_at TEXT",
+		"Cancelled_by TEXT",
+		"Cancel_reason TEXT",
+		"Fail_fast_on TEXT",
+		"Tags TEXT",
+	)
+	if err != nil {
+		return err
+	}
+	err = addMissingColumns(tx, "workers",
+		"Queues TEXT",
+		"Host TEXT",
+		"Pid INTEGER",
+		"State TEXT NOT NULL DEFAULT 'running'",
+	)
+	if err != nil {
+		return err
+	}
+	return addMissingColumns(tx, "schedules", "Queue TEXT NOT NULL DEFAULT 'default'")
+}
+
+func createIndexes(tx migrationTx) error {
+	_, err := tx.Exec(`
+	CREATE INDEX IF NOT EXISTS idx_worker_commands_worker ON worker_commands (WorkerId, Acked_at);
+	CREATE INDEX IF NOT EXISTS idx_job_attempts_job ON job_attempts (JobId, Attempt);
+	CREATE INDEX IF NOT EXISTS idx_job_events_job ON job_events (JobId, Id);
+
+	-- Serve the claim query: pending jobs by priority, then age, either
+	-- across all queues or within one
+	CREATE INDEX IF NOT EXISTS idx_jobs_claim ON jobs (State, Priority DESC, Created_at);
+	CREATE INDEX IF NOT EXISTS idx_jobs_queue_claim ON jobs (Queue, State, Priority DESC, Created_at);
+
+	-- Serve "queuectl list": its default order, time range filters and the
+	-- common filters
+	CREATE INDEX IF NOT EXISTS idx_jobs_created ON jobs (Created_at);
+	CREATE INDEX IF NOT EXISTS idx_jobs_updated ON jobs (Updated_at);
+	CREATE INDEX IF NOT EXISTS idx_jobs_state_created ON jobs (State, Created_at);
+	CREATE INDEX IF NOT EXISTS idx_jobs_queue_created ON jobs (Queue, Created_at);
+	CREATE INDEX IF NOT EXISTS idx_jobs_worker ON jobs (WorkerId);
+
+	-- Never enqueue the same occurrence of a schedule twice
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_schedule_occurrence ON jobs (Schedule_id, Scheduled_for);
+	`)
+	return err
+}
+
+// legacyTimeLayout is how queuectl wrote timestamps before they were UTC
+// RFC3339, in the local time of the machine that wrote them.
+const legacyTimeLayout = "2006-01-02 15:04:05"
+
+// convertLegacyJobs finishes upgrading jobs written before migrations were
+// versioned.
+//
+// Their timestamps are local time in legacyTimeLayout, which doesn't
+// compare correctly with RFC3339, so they are converted to UTC assuming
+// this machine's time zone. The exception is the Next_run_at of a job
+// that never failed: enqueue set it with datetime('now'), which is UTC.
+//
+// Their Max_retries was only filled in once they failed, so 0 means the
+// max-retries config at the time, which defaulted to 3.
+func convertLegacyJobs(tx migrationTx) error {
+	maxRetries := 3
+	var value string
+	err := tx.QueryRow(`SELECT Value FROM config WHERE Key = 'max-retries'`).Scan(&value)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if n, err := strconv.Atoi(value); err == nil && n > 0 {
+		maxRetries = n
+	}
+
+	type legacyJob struct {
+		id                              string
+		attempts, maxRetries            sql.NullInt64
+		createdAt, updatedAt, nextRunAt sql.NullString
+	}
+	const legacy = `LIKE '____-__-__ __:__:__'`
+	rows, err := tx.Query(`
+		SELECT Id, Attempts, Max_retries, Created_at, Updated_at, Next_run_at FROM jobs
+		WHERE Created_at ` + legacy + ` OR Updated_at ` + legacy + ` OR Next_run_at ` + legacy)
+	if err != nil {
+		return err
+	}
+	var jobs []legacyJob
+	for rows.Next() {
+		var j legacyJob
+		if err := rows.Scan(&j.id, &j.attempts, &j.maxRetries, &j.createdAt, &j.updatedAt, &j.nextRunAt); err != nil {
+			rows.Close()
+			return err
+		}
+		jobs = append(jobs, j)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// toUTC converts a legacy timestamp written in loc and leaves any
+	// other value alone
+	toUTC := func(v sql.NullString, loc *time.Location) sql.NullString {
+		t, err := time.ParseInLocation(legacyTimeLayout, v.String, loc)
+		if !v.Valid || err != nil {
+			return v
+		}
+		return sql.NullString{String: t.UTC().Format(time.RFC3339), Valid: true}
+	}
+
+	for _, j := range jobs {
+		nextRunLoc := time.Local
+		if j.attempts.Int64 == 0 {
+			nextRunLoc = time.UTC
+		}
+		// Only jobs from before versioning can be missing Max_retries
+		if _, err := time.Parse(legacyTimeLayout, j.createdAt.String); err == nil && j.maxRetries.Int64 == 0 {
+			j.maxRetries = sql.NullInt64{Int64: int64(maxRetries), Valid: true}
+		}
 
-	fmt.Println("Migration completed successfully")
+		_, err := tx.Exec(`
+			UPDATE jobs SET Max_retries = ?, Created_at = ?, Updated_at = ?, Next_run_at = ?
+			WHERE Id = ?
+		`, j.maxRetries, toUTC(j.createdAt, time.Local), toUTC(j.updatedAt, time.Local), toUTC(j.nextRunAt, nextRunLoc), j.id)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// addJobTypes lets a job name a handler and carry a JSON payload for it,
+// for workers embedded in Go programs.
+func addJobTypes(tx migrationTx) error {
+	_, err := tx.Exec(`
+	ALTER TABLE jobs ADD COLUMN Type TEXT;
+	ALTER TABLE jobs ADD COLUMN Payload TEXT;
+	`)
+	return err
+}
+
+// addMissingColumns adds each column, given as "Name TYPE ...", that table
+// doesn't have yet.
+func addMissingColumns(tx migrationTx, table string, columns ...string) error {
+	rows, err := tx.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return err
+	}
+	existing := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[strings.ToLower(name)] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, column := range columns {
+		name, _, _ := strings.Cut(column, " ")
+		if existing[strings.ToLower(name)] {
+			continue
+		}
+		if _, err := tx.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column); err != nil {
+			return fmt.Errorf("add %s.%s: %w", table, name, err)
+		}
+	}
+	return nil
+}
+
+// migrationTx runs statements on the connection that holds a migration's
+// transaction.
+type migrationTx struct {
+	conn *sql.Conn
+}
+
+func (tx migrationTx) Exec(query string, args ...any) (sql.Result, error) {
+	return tx.conn.ExecContext(context.Background(), query, args...)
+}
+
+func (tx migrationTx) Query(query string, args ...any) (*sql.Rows, error) {
+	return tx.conn.QueryContext(context.Background(), query, args...)
+}
+
+func (tx migrationTx) QueryRow(query string, args ...any) *sql.Row {
+	return tx.conn.QueryRowContext(context.Background(), query, args...)
+}
+
+// rowQuerier is a *sql.DB or migrationTx.
+type rowQuerier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// version reads the schema version. A database without schema_migrations
+// predates versioning and is version 0.
+func (s *Schema) version(q rowQuerier) (int, error) {
+	var exists int
+	err := q.QueryRow(s.versionTable).Scan(&exists)
+	if err != nil || exists == 0 {
+		return 0, err
+	}
+	var v int
+	err = q.QueryRow(`SELECT COALESCE(MAX(Version), 0) FROM schema_migrations`).Scan(&v)
+	return v, err
+}
+
+// Version returns the version of the database's schema.
+func (s *Schema) Version(db *sql.DB) (int, error) {
+	return s.version(db)
+}
+
+// Check fails unless the database is at exactly LatestVersion.
+func (s *Schema) Check(db *sql.DB) error {
+	v, err := s.Version(db)
+	if err != nil {
+		return err
+	}
+	switch {
+	case v > s.LatestVersion():
+		return fmt.Errorf("%w: it is at version %d, this queuectl knows up to %d", ErrSchemaTooNew, v, s.LatestVersion())
+	case v < s.LatestVersion():
+		return fmt.Errorf("%w: it is at version %d, this queuectl needs %d", ErrSchemaOutdated, v, s.LatestVersion())
+	}
+	return nil
+}
+
+// Migrate brings the database to LatestVersion.
+func (s *Schema) Migrate(db *sql.DB) error {
+	_, err := s.MigrateTo(db, s.LatestVersion())
+	return err
+}
+
+// MigrateTo applies the migrations up to and including version target and
+// returns the ones it applied. Migrations only go forward.
+//
+// Every migration takes the lock before it reads the version, so any
+// number of processes may migrate at once and each migration still runs
+// exactly once.
+func (s *Schema) MigrateTo(db *sql.DB, target int) ([]Migration, error) {
+	if target < 0 || target > s.LatestVersion() {
+		return nil, fmt.Errorf("no schema version %d (the latest is %d)", target, s.LatestVersion())
+	}
+
+	current, err := s.Version(db)
+	if err != nil {
+		return nil, err
+	}
+	if current > s.LatestVersion() {
+		return nil, fmt.Errorf("%w: it is at version %d, this queuectl knows up to %d", ErrSchemaTooNew, current, s.LatestVersion())
+	}
+	if target < current {
+		return nil, fmt.Errorf("database is already at version %d; migrations can't be undone", current)
+	}
+
+	var applied []Migration
+	for _, m := range s.migrations {
+		if m.Version > target {
+			break
+		}
+		ran, err := s.apply(db, m)
+		if err != nil {
+			return applied, fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Name, err)
+		}
+		if ran {
+			applied = append(applied, m)
+		}
+	}
+	return applied, nil
+}
+
+// apply runs m unless the database is already past it, and reports
+// whether it did.
+func (s *Schema) apply(db *sql.DB, m Migration) (bool, error) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	for i, stmt := range s.begin {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			if i > 0 {
+				conn.ExecContext(ctx, `ROLLBACK`)
+			}
+			return false, err
+		}
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			conn.ExecContext(ctx, `ROLLBACK`)
+		}
+	}()
+
+	tx := migrationTx{conn}
+	_, err = tx.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			Version INTEGER PRIMARY KEY,
+			Name TEXT NOT NULL,
+			Applied_at TEXT NOT NULL
+		)
+	`)
+	if err != nil {
+		return false, err
+	}
+
+	current, err := s.version(tx)
+	if err != nil {
+		return false, err
+	}
+	if current >= m.Version {
+		return false, nil
+	}
+
+	if err := m.up(tx); err != nil {
+		return false, err
+	}
+	_, err = tx.Exec(s.recordVersion, m.Version, m.Name, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := conn.ExecContext(ctx, `COMMIT`); err != nil {
+		return false, err
+	}
+	committed = true
+	return true, nil
+}
+
+// MigrationStatus is one migration and, if the database has run it, when.
+type MigrationStatus struct {
+	Version    int
+	Name       string
+	Applied_at string
+}
+
+// Status lists every migration this binary knows and any newer ones the
+// database has run, oldest first.
+func (s *Schema) Status(db *sql.DB) ([]MigrationStatus, error) {
+	applied := map[int]MigrationStatus{}
+	v, err := s.Version(db)
+	if err != nil {
+		return nil, err
+	}
+	if v > 0 {
+		rows, err := db.Query(`SELECT Version, Name, Applied_at FROM schema_migrations ORDER BY Version`)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var m MigrationStatus
+			if err := rows.Scan(&m.Version, &m.Name, &m.Applied_at); err != nil {
+				return nil, err
+			}
+			applied[m.Version] = m
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	var status []MigrationStatus
+	for _, m := range s.migrations {
+		ms := MigrationStatus{Version: m.Version, Name: m.Name}
+		if a, ok := applied[m.Version]; ok {
+			ms.Applied_at = a.Applied_at
+		}
+		status = append(status, ms)
+		delete(applied, m.Version)
+	}
+	for _, m := range applied {
+		status = append(status, m)
+	}
+	slices.SortFunc(status, func(a, b MigrationStatus) int { return a.Version - b.Version })
+	return status, nil
+}
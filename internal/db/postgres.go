@@ -0,0 +1,187 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// IsPostgres reports whether dsn is a PostgreSQL connection URL rather
+// than the path of an SQLite file.
+func IsPostgres(dsn string) bool {
+	return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://")
+}
+
+// Redact returns dsn with any password in it masked, for messages.
+func Redact(dsn string) string {
+	if !IsPostgres(dsn) {
+		return dsn
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "postgres://…"
+	}
+	return u.Redacted()
+}
+
+// OpenPostgres connects to the PostgreSQL database dsn names. Unlike an
+// SQLite file the database itself must already exist; "queuectl db init"
+// only creates the tables in it.
+func OpenPostgres(dsn string) (*sql.DB, error) {
+	database, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := database.Ping(); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("cannot connect to %s: %w", Redact(dsn), err)
+	}
+	return database, nil
+}
+
+// Postgres is the schema of a PostgreSQL database. It matches SQLite's
+// column for column; timestamps stay RFC3339 text so both compare them the
+// same way. jobs.Seq stands in for SQLite's rowid.
+var Postgres = &Schema{
+	Name: "PostgreSQL",
+	migrations: []Migration{
+		{Version: 1, Name: "create tables", up: createPostgresTables},
+		{Version: 2, Name: "create indexes", up: createIndexes},
+		{Version: 3, Name: "convert legacy jobs", up: noLegacyJobs},
+		{Version: 4, Name: "add job types", up: addJobTypes},
+	},
+	// The advisory lock serializes migrations the way BEGIN IMMEDIATE
+	// does on SQLite; it is released when the transaction ends
+	begin: []string{
+		`BEGIN`,
+		`SELECT pg_advisory_xact_lock(7166320386519512)`,
+	},
+	versionTable:  `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'schema_migrations'`,
+	recordVersion: `INSERT INTO schema_migrations (Version, Name, Applied_at) VALUES ($1, $2, $3)`,
+}
+
+func createPostgresTables(tx migrationTx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS jobs (
+		Seq BIGSERIAL UNIQUE,
+		Id TEXT PRIMARY KEY,
+		Queue TEXT NOT NULL DEFAULT 'default',
+		Command TEXT,
+		State TEXT,
+		Attempts INTEGER,
+		Max_retries INTEGER,
+		Priority INTEGER NOT NULL DEFAULT 0,
+		WorkerId TEXT,
+		Claim_token TEXT,
+		Lease_expires_at TEXT,
+		Timeout_seconds INTEGER,
+		Backoff_strategy TEXT,
+		Backoff_base_seconds INTEGER,
+		Backoff_max_seconds INTEGER,
+		Last_backoff_seconds INTEGER,
+		Retry_on TEXT,
+		Fail_fast_on TEXT,
+		Cancel_requested_at TEXT,
+		Cancelled_at TEXT,
+		Cancelled_by TEXT,
+		Cancel_reason TEXT,
+		Failure_reason TEXT,
+		Env TEXT,
+		Tags TEXT,
+		Schedule_id TEXT,
+		Scheduled_for TEXT,
+		Next_run_at TEXT,
+		Created_at TEXT,
+		Updated_at TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS workers (
+		WorkerId TEXT PRIMARY KEY,
+		Started_at TEXT,
+		Last_heartbeat TEXT,
+		Queues TEXT,
+		Host TEXT,
+		Pid INTEGER,
+		State TEXT NOT NULL DEFAULT 'running'
+	);
+
+	CREATE TABLE IF NOT EXISTS worker_commands (
+		Id BIGSERIAL PRIMARY KEY,
+		WorkerId TEXT NOT NULL,
+		Command TEXT NOT NULL,
+		Created_at TEXT,
+		Acked_at TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS config (
+		Key TEXT PRIMARY KEY,
+		Value TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS queue_config (
+		Queue TEXT NOT NULL,
+		Key TEXT NOT NULL,
+		Value TEXT,
+		PRIMARY KEY (Queue, Key)
+	);
+
+	CREATE TABLE IF NOT EXISTS job_attempts (
+		Id BIGSERIAL PRIMARY KEY,
+		JobId TEXT NOT NULL,
+		Attempt INTEGER NOT NULL,
+		WorkerId TEXT,
+		Started_at TEXT,
+		Finished_at TEXT,
+		Exit_code INTEGER,
+		Signal TEXT,
+		Stdout TEXT,
+		Stderr TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS job_events (
+		Id BIGSERIAL PRIMARY KEY,
+		JobId TEXT NOT NULL,
+		At TEXT NOT NULL,
+		Event TEXT NOT NULL,
+		From_state TEXT,
+		To_state TEXT,
+		Attempt INTEGER,
+		WorkerId TEXT,
+		Actor TEXT,
+		Detail TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS schedules (
+		Id TEXT PRIMARY KEY,
+		Queue TEXT NOT NULL DEFAULT 'default',
+		Cron TEXT NOT NULL,
+		Command TEXT NOT NULL,
+		Max_retries INTEGER,
+		Timeout_seconds INTEGER,
+		Catch_up TEXT NOT NULL DEFAULT 'once',
+		Paused INTEGER NOT NULL DEFAULT 0,
+		Next_run_at TEXT NOT NULL,
+		Last_run_at TEXT,
+		Created_at TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS paused_queues (
+		Queue TEXT PRIMARY KEY,
+		Paused_at TEXT NOT NULL,
+		Paused_by TEXT,
+		Reason TEXT,
+		Resume_at TEXT
+	);
+	`)
+	return err
+}
+
+// noLegacyJobs keeps PostgreSQL at the same version as SQLite. queuectl
+// has only supported PostgreSQL since migrations were versioned, so it has
+// no legacy jobs to convert.
+func noLegacyJobs(tx migrationTx) error {
+	return nil
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+)
+
+func (s *sqlStore) ConfigValue(queue, key string) (string, string, error) {
+	var value string
+
+	if queue != "" {
+		err := s.db.QueryRow(`SELECT Value FROM queue_config WHERE Queue = ? AND Key = ?`, queue, key).Scan(&value)
+		if err == nil {
+			return value, "queue", nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return "", "", err
+		}
+	}
+
+	err := s.db.QueryRow(`SELECT Value FROM config WHERE Key = ?`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return value, "global", nil
+}
+
+func (s *sqlStore) SetConfig(queue, key, value string) error {
+	var err error
+	if queue != "" {
+		_, err = s.db.Exec(`
+			INSERT INTO queue_config (Queue, Key, Value)
+			VALUES (?, ?, ?)
+			ON CONFLICT(Queue, Key) DO UPDATE SET Value=excluded.Value
+		`, queue, key, value)
+	} else {
+		_, err = s.db.Exec(`
+			INSERT INTO config (Key, Value)
+			VALUES (?, ?)
+			ON CONFLICT(Key) DO UPDATE SET Value=excluded.Value
+		`, key, value)
+	}
+	return err
+}
+
+func (s *sqlStore) UnsetConfig(queue, key string) error {
+	var err error
+	if queue != "" {
+		_, err = s.db.Exec(`DELETE FROM queue_config WHERE Queue = ? AND Key = ?`, queue, key)
+	} else {
+		_, err = s.db.Exec(`DELETE FROM config WHERE Key = ?`, key)
+	}
+	return err
+}
+
+func (s *sqlStore) QueueConfig() ([]QueueSetting, error) {
+	rows, err := s.db.Query(`SELECT Queue, Key, Value FROM queue_config ORDER BY Queue, Key`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var settings []QueueSetting
+	for rows.Next() {
+		var q QueueSetting
+		if err := rows.Scan(&q.Queue, &q.Key, &q.Value); err != nil {
+			return nil, err
+		}
+		settings = append(settings, q)
+	}
+	return settings, rows.Err()
+}
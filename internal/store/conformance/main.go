@@ -0,0 +1,1074 @@
+// Command conformance runs the same checks against every Store backend, so
+// SQLite and PostgreSQL can't drift apart:
+//
+//	go run ./internal/store/conformance data/test.db postgres://localhost/queuectl_test
+//
+// Each argument is a database as --db takes it. It is created and migrated
+// if needed; the checks only touch queues and config keys of their own, so
+// a database in use can be checked too. The exit status is 1 if any check
+// failed on any backend.
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"queuectl/internal/db"
+	"queuectl/internal/store"
+
+	"github.com/google/uuid"
+)
+
+// check tests one part of the Store. queue is a name no other check or
+// run uses.
+type check struct {
+	name string
+	run  func(st store.Store, queue string) error
+}
+
+var checks = []check{
+	{"enqueue and get", checkEnqueue},
+	{"enqueue is all or nothing", checkEnqueueAtomic},
+	{"claim order", checkClaimOrder},
+	{"priority aging", checkPriorityAging},
+	{"concurrent claims", checkConcurrentClaims},
+	{"claim token", checkClaimToken},
+	{"typed jobs", checkTypedJobs},
+	{"fail, retry and dead", checkFail},
+	{"requeue", checkRequeue},
+	{"cancel", checkCancel},
+	{"retry from the DLQ", checkRetryDead},
+	{"attempts", checkAttempts},
+	{"history", checkEvents},
+	{"heartbeat and expired leases", checkLeases},
+	{"worker commands", checkWorkerCommands},
+	{"queue pauses", checkQueuePauses},
+	{"schedules", checkSchedules},
+	{"config precedence", checkConfig},
+	{"list filters", checkListFilters},
+	{"list paging", checkListPaging},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: conformance <database>...")
+		os.Exit(2)
+	}
+
+	failed := 0
+	for _, dsn := range os.Args[1:] {
+		failed += runChecks(dsn)
+	}
+
+	if failed > 0 {
+		fmt.Printf("\n❌ %d check(s) failed\n", failed)
+		os.Exit(1)
+	}
+	fmt.Println("\n✅ All checks passed")
+}
+
+// runChecks runs every check against dsn and returns how many failed.
+func runChecks(dsn string) int {
+	conn, schema, err := db.Connect(dsn, db.Options{Create: true})
+	if err == nil {
+		err = schema.Migrate(conn)
+	}
+	if err != nil {
+		fmt.Printf("❌ %s: %v\n", db.Redact(dsn), err)
+		return 1
+	}
+	st := store.New(conn, schema)
+	defer st.Close()
+
+	fmt.Printf("===== %s (%s) =====\n", schema.Name, db.Redact(dsn))
+
+	run := uuid.NewString()[:8]
+	failed := 0
+	for i, c := range checks {
+		queue := fmt.Sprintf("conformance-%s-%d", run, i)
+		if err := c.run(st, queue); err != nil {
+			fmt.Printf("  FAIL  %s: %v\n", c.name, err)
+			failed++
+			continue
+		}
+		fmt.Printf("  ok    %s\n", c.name)
+	}
+	return failed
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// newJob is a pending job of queue that is ready to run.
+func newJob(queue string, n, priority int) store.NewJob {
+	now := formatTime(time.Now())
+	return store.NewJob{
+		Id:         fmt.Sprintf("%s-%d", queue, n),
+		Queue:      queue,
+		Command:    fmt.Sprintf("echo job %d", n),
+		State:      "pending",
+		MaxRetries: 3,
+		Priority:   priority,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		NextRunAt:  sql.NullString{String: now, Valid: true},
+		Actor:      "conformance",
+	}
+}
+
+func enqueue(st store.Store, jobs ...store.NewJob) error {
+	if err := st.Enqueue(jobs); err != nil {
+		return fmt.Errorf("enqueue: %w", err)
+	}
+	return nil
+}
+
+func claim(st store.Store, queue string) (*store.ClaimedJob, error) {
+	job, err := st.Claim(store.ClaimRequest{WorkerId: "conformance", Queue: queue, Lease: time.Minute})
+	if err != nil {
+		return nil, fmt.Errorf("claim: %w", err)
+	}
+	return job, nil
+}
+
+// expectState fails unless job id is in state.
+func expectState(st store.Store, id, state string) error {
+	job, err := st.GetJob(id)
+	if err != nil {
+		return fmt.Errorf("get %s: %w", id, err)
+	}
+	if job.State != state {
+		return fmt.Errorf("job %s is %s, want %s", id, job.State, state)
+	}
+	return nil
+}
+
+func checkEnqueue(st store.Store, queue string) error {
+	job := newJob(queue, 1, 5)
+	job.Tags = sql.NullString{String: `["a","b"]`, Valid: true}
+	job.TimeoutSeconds = sql.NullInt64{Int64: 30, Valid: true}
+	if err := enqueue(st, job); err != nil {
+		return err
+	}
+
+	got, err := st.GetJob(job.Id)
+	if err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+	if got.Queue != queue || got.Command != job.Command || got.State != "pending" ||
+		got.Priority != 5 || got.MaxRetries != 3 || got.Attempts != 0 {
+		return fmt.Errorf("got %+v", got)
+	}
+	if got.Tags != job.Tags || got.TimeoutSeconds != job.TimeoutSeconds {
+		return fmt.Errorf("tags %v and timeout %v didn't round-trip", got.Tags, got.TimeoutSeconds)
+	}
+	if got.Seq == 0 {
+		return errors.New("job has no seq")
+	}
+
+	if _, err := st.GetJob(queue + "-missing"); !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("get of a missing job returned %v, want sql.ErrNoRows", err)
+	}
+	return nil
+}
+
+func checkEnqueueAtomic(st store.Store, queue string) error {
+	// The second job has the same id, so neither may be added
+	if err := st.Enqueue([]store.NewJob{newJob(queue, 1, 0), newJob(queue, 1, 0)}); err == nil {
+		return errors.New("enqueue of a duplicate id succeeded")
+	}
+	n, err := st.CountJobs(store.ListFilter{Queue: queue})
+	if err != nil {
+		return fmt.Errorf("count: %w", err)
+	}
+	if n != 0 {
+		return fmt.Errorf("%d job(s) added by a failed enqueue", n)
+	}
+	return nil
+}
+
+func checkClaimOrder(st store.Store, queue string) error {
+	later := newJob(queue, 1, 100)
+	later.NextRunAt = sql.NullString{String: formatTime(time.Now().Add(time.Hour)), Valid: true}
+	if err := enqueue(st, newJob(queue, 2, 1), newJob(queue, 3, 10), later); err != nil {
+		return err
+	}
+
+	// The job that isn't due yet is skipped despite its priority
+	for _, want := range []string{queue + "-3", queue + "-2"} {
+		job, err := claim(st, queue)
+		if err != nil {
+			return err
+		}
+		if job.Id != want {
+			return fmt.Errorf("claimed %s, want %s", job.Id, want)
+		}
+		if job.ClaimToken == "" || job.Queue != queue {
+			return fmt.Errorf("claimed job is missing its token or queue: %+v", job)
+		}
+		if err := expectState(st, job.Id, "processing"); err != nil {
+			return err
+		}
+	}
+
+	if _, err := claim(st, queue); !errors.Is(err, store.ErrNoJob) {
+		return fmt.Errorf("claim of an empty queue returned %v, want ErrNoJob", err)
+	}
+
+	// Jobs of one priority enqueued in the same second run in the order
+	// they were enqueued. The ids are in reverse so they can't decide.
+	var batch []store.NewJob
+	for n := 9; n >= 4; n-- {
+		batch = append(batch, newJob(queue, n, 0))
+	}
+	if err := enqueue(st, batch...); err != nil {
+		return err
+	}
+	for _, want := range batch {
+		job, err := claim(st, queue)
+		if err != nil {
+			return err
+		}
+		if job.Id != want.Id {
+			return fmt.Errorf("claimed %s, want %s", job.Id, want.Id)
+		}
+	}
+	return nil
+}
+
+func checkPriorityAging(st store.Store, queue string) error {
+	// Ready for two hours, so aging by one every ten minutes lifts it
+	// from 0 to 12, past the job that became ready just now
+	old := newJob(queue, 1, 0)
+	old.NextRunAt = sql.NullString{String: formatTime(time.Now().Add(-2 * time.Hour)), Valid: true}
+	if err := enqueue(st, old, newJob(queue, 2, 5), newJob(queue, 3, 20)); err != nil {
+		return err
+	}
+
+	for _, want := range []string{queue + "-3", queue + "-1", queue + "-2"} {
+		job, err := st.Claim(store.ClaimRequest{WorkerId: "conformance", Queue: queue, Lease: time.Minute, PriorityAging: 600})
+		if err != nil {
+			return fmt.Errorf("claim: %w", err)
+		}
+		if job.Id != want {
+			return fmt.Errorf("claimed %s, want %s", job.Id, want)
+		}
+	}
+	return nil
+}
+
+func checkTypedJobs(st store.Store, queue string) error {
+	typed := newJob(queue, 1, 10)
+	typed.Command = ""
+	typed.Type = sql.NullString{String: "send-email", Valid: true}
+	typed.Payload = sql.NullString{String: `{"to":"ops"}`, Valid: true}
+	if err := enqueue(st, typed, newJob(queue, 2, 0)); err != nil {
+		return err
+	}
+
+	// Without a handler for its type the typed job is skipped despite its
+	// priority
+	job, err := claim(st, queue)
+	if err != nil {
+		return err
+	}
+	if job.Id != queue+"-2" {
+		return fmt.Errorf("claimed %s, want %s-2", job.Id, queue)
+	}
+	_, err = st.Claim(store.ClaimRequest{WorkerId: "conformance", Queue: queue, Types: []string{"other"}, Lease: time.Minute})
+	if !errors.Is(err, store.ErrNoJob) {
+		return fmt.Errorf("claim for another type returned %v, want ErrNoJob", err)
+	}
+
+	job, err = st.Claim(store.ClaimRequest{WorkerId: "conformance", Queue: queue, Types: []string{"other", "send-email"}, Lease: time.Minute})
+	if err != nil {
+		return fmt.Errorf("claim: %w", err)
+	}
+	if job.Id != typed.Id || job.Type != typed.Type || job.Payload != typed.Payload {
+		return fmt.Errorf("claimed %s with type %v and payload %v", job.Id, job.Type, job.Payload)
+	}
+
+	got, _, err := st.ListJobs(store.ListFilter{Queue: queue, Type: "send-email"})
+	if err != nil {
+		return fmt.Errorf("list: %w", err)
+	}
+	if len(got) != 1 || got[0].Id != typed.Id || got[0].Payload != typed.Payload {
+		return fmt.Errorf("list by type returned %+v", got)
+	}
+	return nil
+}
+
+func checkConcurrentClaims(st store.Store, queue string) error {
+	const jobs, workers = 40, 8
+
+	var batch []store.NewJob
+	for i := 0; i < jobs; i++ {
+		batch = append(batch, newJob(queue, i, 0))
+	}
+	if err := enqueue(st, batch...); err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	claimed := map[string]int{}
+	var errs []error
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				job, err := claim(st, queue)
+				mu.Lock()
+				if err == nil {
+					claimed[job.Id]++
+				} else if !errors.Is(err, store.ErrNoJob) {
+					errs = append(errs, err)
+				}
+				mu.Unlock()
+				if err != nil {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	if len(claimed) != jobs {
+		return fmt.Errorf("%d of %d jobs claimed", len(claimed), jobs)
+	}
+	for id, n := range claimed {
+		if n != 1 {
+			return fmt.Errorf("job %s claimed %d times", id, n)
+		}
+	}
+	return nil
+}
+
+func checkClaimToken(st store.Store, queue string) error {
+	if err := enqueue(st, newJob(queue, 1, 0)); err != nil {
+		return err
+	}
+	job, err := claim(st, queue)
+	if err != nil {
+		return err
+	}
+
+	stale := *job
+	stale.ClaimToken = uuid.NewString()
+	done, err := st.Complete(&stale, store.Event{Event: "completed", FromState: "processing", ToState: "completed"})
+	if err != nil {
+		return fmt.Errorf("complete: %w", err)
+	}
+	if done {
+		return errors.New("complete with another claim token applied")
+	}
+
+	done, err = st.Complete(job, store.Event{Event: "completed", FromState: "processing", ToState: "completed"})
+	if err != nil {
+		return fmt.Errorf("complete: %w", err)
+	}
+	if !done {
+		return errors.New("complete with the claim token didn't apply")
+	}
+	return expectState(st, job.Id, "completed")
+}
+
+func checkFail(st store.Store, queue string) error {
+	if err := enqueue(st, newJob(queue, 1, 0)); err != nil {
+		return err
+	}
+	job, err := claim(st, queue)
+	if err != nil {
+		return err
+	}
+
+	// A retry that is due right away, so it can be claimed again
+	_, err = st.Fail(job, store.Failure{
+		Attempts:       1,
+		Reason:         "exit code 1",
+		NextRunAt:      formatTime(time.Now()),
+		BackoffSeconds: 2,
+		Event:          store.Event{Event: "failed", FromState: "processing", ToState: "failed"},
+		Retry:          store.Event{Event: "retry_scheduled", FromState: "failed", ToState: "pending"},
+	})
+	if err != nil {
+		return fmt.Errorf("fail: %w", err)
+	}
+	if err := expectState(st, job.Id, "pending"); err != nil {
+		return err
+	}
+
+	job, err = claim(st, queue)
+	if err != nil {
+		return err
+	}
+	if job.Attempts != 1 || job.LastBackoffSeconds.Int64 != 2 {
+		return fmt.Errorf("retried job has attempts %d and backoff %v, want 1 and 2", job.Attempts, job.LastBackoffSeconds)
+	}
+
+	_, err = st.Fail(job, store.Failure{
+		Attempts: 2,
+		Reason:   "exit code 1",
+		Dead:     true,
+		Event:    store.Event{Event: "dead", FromState: "processing", ToState: "dead"},
+	})
+	if err != nil {
+		return fmt.Errorf("fail: %w", err)
+	}
+	got, err := st.GetJob(job.Id)
+	if err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+	if got.State != "dead" || got.Attempts != 2 || got.FailureReason.String != "exit code 1" {
+		return fmt.Errorf("dead job is %s with %d attempts and reason %q", got.State, got.Attempts, got.FailureReason.String)
+	}
+	return nil
+}
+
+func checkRequeue(st store.Store, queue string) error {
+	if err := enqueue(st, newJob(queue, 1, 0)); err != nil {
+		return err
+	}
+	job, err := claim(st, queue)
+	if err != nil {
+		return err
+	}
+
+	_, err = st.Requeue(job, store.Requeue{
+		Reason:    "interrupted",
+		NextRunAt: formatTime(time.Now()),
+		Event:     store.Event{Event: "interrupted", FromState: "processing", ToState: "pending"},
+	})
+	if err != nil {
+		return fmt.Errorf("requeue: %w", err)
+	}
+
+	got, err := st.GetJob(job.Id)
+	if err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+	if got.State != "pending" || got.Attempts != 0 || got.WorkerId.Valid {
+		return fmt.Errorf("requeued job is %s with %d attempts and worker %q", got.State, got.Attempts, got.WorkerId.String)
+	}
+	return nil
+}
+
+func checkCancel(st store.Store, queue string) error {
+	if err := enqueue(st, newJob(queue, 1, 1), newJob(queue, 2, 0)); err != nil {
+		return err
+	}
+	c := store.Cancellation{By: "conformance", Reason: "testing"}
+
+	// A waiting job is only cancelled from the state it was seen in
+	if ok, err := st.Cancel(queue+"-2", "failed", c); err != nil || ok {
+		return fmt.Errorf("cancel from the wrong state: %v, %v", ok, err)
+	}
+	if ok, err := st.Cancel(queue+"-2", "pending", c); err != nil || !ok {
+		return fmt.Errorf("cancel pending job: %v, %v", ok, err)
+	}
+	got, err := st.GetJob(queue + "-2")
+	if err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+	if got.State != "cancelled" || got.CancelledBy.String != "conformance" || got.CancelReason.String != "testing" {
+		return fmt.Errorf("cancelled job is %s, by %q because %q", got.State, got.CancelledBy.String, got.CancelReason.String)
+	}
+
+	// A running job is left to its worker, and only asked once
+	job, err := claim(st, queue)
+	if err != nil {
+		return err
+	}
+	if ok, err := st.Cancel(job.Id, "pending", c); err != nil || ok {
+		return fmt.Errorf("cancel processing job right away: %v, %v", ok, err)
+	}
+	if ok, err := st.RequestCancel(job.Id, c); err != nil || !ok {
+		return fmt.Errorf("request cancel: %v, %v", ok, err)
+	}
+	if ok, err := st.RequestCancel(job.Id, c); err != nil || ok {
+		return fmt.Errorf("request cancel twice: %v, %v", ok, err)
+	}
+	if requested, err := st.CancelRequested(job.Id, "conformance"); err != nil || !requested {
+		return fmt.Errorf("cancel requested: %v, %v", requested, err)
+	}
+	return expectState(st, job.Id, "processing")
+}
+
+func checkRetryDead(st store.Store, queue string) error {
+	if err := enqueue(st, newJob(queue, 1, 0)); err != nil {
+		return err
+	}
+	id := queue + "-1"
+	if ok, err := st.RetryDead(id, sql.NullInt64{}, "conformance"); err != nil || ok {
+		return fmt.Errorf("retry a pending job: %v, %v", ok, err)
+	}
+
+	job, err := claim(st, queue)
+	if err != nil {
+		return err
+	}
+	_, err = st.Fail(job, store.Failure{
+		Attempts: 1,
+		Reason:   "exit code 1",
+		Dead:     true,
+		Event:    store.Event{Event: "dead", FromState: "processing", ToState: "dead"},
+	})
+	if err != nil {
+		return fmt.Errorf("fail: %w", err)
+	}
+
+	if ok, err := st.RetryDead(id, sql.NullInt64{Int64: 5, Valid: true}, "conformance"); err != nil || !ok {
+		return fmt.Errorf("retry dead job: %v, %v", ok, err)
+	}
+	got, err := st.GetJob(id)
+	if err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+	if got.State != "pending" || got.Attempts != 0 || got.MaxRetries != 5 || got.WorkerId.Valid {
+		return fmt.Errorf("retried job is %s with %d/%d attempts and worker %q", got.State, got.Attempts, got.MaxRetries, got.WorkerId.String)
+	}
+	return nil
+}
+
+func checkAttempts(st store.Store, queue string) error {
+	if err := enqueue(st, newJob(queue, 1, 0)); err != nil {
+		return err
+	}
+	id := queue + "-1"
+
+	for want := 1; want <= 2; want++ {
+		attemptId, n, err := st.StartAttempt(id, "conformance")
+		if err != nil {
+			return fmt.Errorf("start attempt: %w", err)
+		}
+		if n != want {
+			return fmt.Errorf("attempt number %d, want %d", n, want)
+		}
+		if err := st.SaveAttemptOutput(attemptId, "partial", ""); err != nil {
+			return fmt.Errorf("save output: %w", err)
+		}
+		if err := st.FinishAttempt(attemptId, store.AttemptResult{ExitCode: 1, Stdout: "done"}); err != nil {
+			return fmt.Errorf("finish attempt: %w", err)
+		}
+	}
+
+	attempts, err := st.JobAttempts(id, 0)
+	if err != nil {
+		return fmt.Errorf("job attempts: %w", err)
+	}
+	if len(attempts) != 2 {
+		return fmt.Errorf("got %d attempts, want 2", len(attempts))
+	}
+	for i, a := range attempts {
+		if a.Attempt != i+1 || a.WorkerId.String != "conformance" || !a.FinishedAt.Valid ||
+			a.ExitCode != (sql.NullInt64{Int64: 1, Valid: true}) || a.Stdout.String != "done" {
+			return fmt.Errorf("attempt %d is %+v", i+1, a)
+		}
+	}
+	if attempts, err = st.JobAttempts(id, 2); err != nil {
+		return fmt.Errorf("job attempts: %w", err)
+	}
+	if len(attempts) != 1 || attempts[0].Attempt != 2 {
+		return fmt.Errorf("got attempts %+v, want only attempt 2", attempts)
+	}
+	return nil
+}
+
+func checkEvents(st store.Store, queue string) error {
+	if err := enqueue(st, newJob(queue, 1, 0)); err != nil {
+		return err
+	}
+	job, err := claim(st, queue)
+	if err != nil {
+		return err
+	}
+	e := store.Event{Event: "completed", FromState: "processing", ToState: "completed", Attempt: 1, WorkerId: "conformance"}
+	if _, err := st.Complete(job, e); err != nil {
+		return fmt.Errorf("complete: %w", err)
+	}
+
+	events, err := st.JobEvents(job.Id)
+	if err != nil {
+		return fmt.Errorf("job events: %w", err)
+	}
+	if len(events) != 2 {
+		return fmt.Errorf("got %d events, want 2", len(events))
+	}
+	if got := events[0]; got.Event != "enqueued" || got.FromState != "" || got.ToState != "pending" || got.Actor != "conformance" {
+		return fmt.Errorf("first event is %+v, want the enqueue", got)
+	}
+	if got := events[1]; got.Event != e.Event || got.FromState != e.FromState || got.ToState != e.ToState ||
+		got.Attempt != 1 || got.WorkerId != e.WorkerId || got.At == "" {
+		return fmt.Errorf("second event is %+v, want %+v", got, e)
+	}
+	return nil
+}
+
+func checkLeases(st store.Store, queue string) error {
+	alive, gone := queue+"-alive", queue+"-gone"
+	for _, w := range []string{alive, gone} {
+		if err := st.RegisterWorker(store.Worker{Id: w, Queues: queue, Host: "conformance", Pid: os.Getpid()}); err != nil {
+			return fmt.Errorf("register worker: %w", err)
+		}
+		defer st.UnregisterWorker(w)
+	}
+	if err := enqueue(st, newJob(queue, 1, 1), newJob(queue, 2, 0)); err != nil {
+		return err
+	}
+
+	// The heartbeat sets the lease of a worker's jobs; a lease in the past
+	// stands in for a worker that stopped sending them. The live lease
+	// never runs out, so another run reaping at the same time can't take
+	// that job.
+	var jobs []*store.ClaimedJob
+	for _, w := range []string{alive, gone} {
+		job, err := st.Claim(store.ClaimRequest{WorkerId: w, Queue: queue, Lease: time.Minute})
+		if err != nil {
+			return fmt.Errorf("claim: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	if err := st.Heartbeat(alive, time.Minute); err != nil {
+		return fmt.Errorf("heartbeat: %w", err)
+	}
+	if err := st.Heartbeat(gone, -time.Minute); err != nil {
+		return fmt.Errorf("heartbeat: %w", err)
+	}
+
+	// Another run sharing the database may reap the lease first, so the
+	// job's state tells whether it was reaped, not the count
+	if _, _, err := st.ReapExpiredLeases(time.Minute); err != nil {
+		return fmt.Errorf("reap: %w", err)
+	}
+	if err := expectState(st, jobs[0].Id, "processing"); err != nil {
+		return err
+	}
+	got, err := st.GetJob(jobs[1].Id)
+	if err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+	if got.State != "pending" || got.Attempts != 1 || got.FailureReason.String != "lease_expired" {
+		return fmt.Errorf("reaped job is %s with %d attempts and reason %q", got.State, got.Attempts, got.FailureReason.String)
+	}
+	return nil
+}
+
+func checkWorkerCommands(st store.Store, queue string) error {
+	worker := queue + "-worker"
+	if err := st.RegisterWorker(store.Worker{Id: worker, Queues: queue, Host: "conformance", Pid: os.Getpid()}); err != nil {
+		return fmt.Errorf("register worker: %w", err)
+	}
+	defer st.UnregisterWorker(worker)
+
+	if err := st.SetWorkerState(worker, "paused"); err != nil {
+		return fmt.Errorf("set worker state: %w", err)
+	}
+
+	workers, err := st.Workers()
+	if err != nil {
+		return fmt.Errorf("workers: %w", err)
+	}
+	i := slices.IndexFunc(workers, func(w store.WorkerStatus) bool { return w.Id == worker })
+	if i < 0 {
+		return errors.New("workers doesn't list the registered worker")
+	}
+	if w := workers[i]; w.Queues != queue || w.Host != "conformance" || w.Pid != os.Getpid() || w.State != "paused" || w.LastHeartbeat == "" {
+		return fmt.Errorf("worker is %+v", w)
+	}
+
+	since := formatTime(time.Now())
+	for _, command := range []string{"pause", "resume"} {
+		if err := st.SendWorkerCommand([]string{worker}, command); err != nil {
+			return fmt.Errorf("send command: %w", err)
+		}
+	}
+
+	commands, err := st.WorkerCommands(worker)
+	if err != nil {
+		return fmt.Errorf("worker commands: %w", err)
+	}
+	if len(commands) != 2 || commands[0].Command != "pause" || commands[1].Command != "resume" {
+		return fmt.Errorf("got commands %+v, want pause then resume", commands)
+	}
+	if err := st.AckWorkerCommand(commands[0].Id); err != nil {
+		return fmt.Errorf("ack: %w", err)
+	}
+	if commands, err = st.WorkerCommands(worker); err != nil {
+		return fmt.Errorf("worker commands: %w", err)
+	}
+	if len(commands) != 1 || commands[0].Command != "resume" {
+		return fmt.Errorf("got commands %+v after the ack, want resume", commands)
+	}
+
+	// Other runs may be sending commands too; only this worker's count
+	sent, err := st.SentWorkerCommands(since, 1000)
+	if err != nil {
+		return fmt.Errorf("sent worker commands: %w", err)
+	}
+	var acked []bool
+	for _, c := range sent {
+		if c.WorkerId == worker {
+			acked = append(acked, c.AckedAt != "")
+		}
+	}
+	if !slices.Equal(acked, []bool{false, true}) {
+		return fmt.Errorf("sent commands acknowledged %v, want resume waiting and pause acknowledged", acked)
+	}
+	return nil
+}
+
+func checkQueuePauses(st store.Store, queue string) error {
+	if err := enqueue(st, newJob(queue, 1, 0), newJob(queue, 2, 0)); err != nil {
+		return err
+	}
+	if _, err := claim(st, queue); err != nil {
+		return err
+	}
+
+	counts, err := st.QueueCounts()
+	if err != nil {
+		return fmt.Errorf("queue counts: %w", err)
+	}
+	var got []store.QueueCount
+	for _, c := range counts {
+		if c.Queue == queue {
+			got = append(got, c)
+		}
+	}
+	want := []store.QueueCount{{Queue: queue, State: "pending", Count: 1}, {Queue: queue, State: "processing", Count: 1}}
+	if !slices.Equal(got, want) {
+		return fmt.Errorf("got counts %+v, want %+v", got, want)
+	}
+
+	if err := st.PauseQueue(store.QueuePause{Queue: queue, PausedBy: "conformance"}); err != nil {
+		return fmt.Errorf("pause: %w", err)
+	}
+	defer st.ResumeQueue(queue)
+	paused, err := st.PausedQueues()
+	if err != nil {
+		return fmt.Errorf("paused queues: %w", err)
+	}
+	if p := paused[queue]; p.PausedBy != "conformance" || p.PausedAt == "" || p.Reason != "" || p.ResumeAt != "" {
+		return fmt.Errorf("pause is %+v", p)
+	}
+	if _, err := st.Claim(store.ClaimRequest{WorkerId: "conformance", Queue: queue, Lease: time.Minute}); err != store.ErrNoJob {
+		return fmt.Errorf("claim from a paused queue: got %v, want ErrNoJob", err)
+	}
+
+	// Pausing again replaces the pause; one that ran out no longer counts
+	expired := store.QueuePause{Queue: queue, Reason: "expired", ResumeAt: formatTime(time.Now().Add(-time.Minute))}
+	if err := st.PauseQueue(expired); err != nil {
+		return fmt.Errorf("pause: %w", err)
+	}
+	if paused, err = st.PausedQueues(); err != nil {
+		return fmt.Errorf("paused queues: %w", err)
+	}
+	if p, ok := paused[queue]; ok {
+		return fmt.Errorf("expired pause is still listed: %+v", p)
+	}
+	if resumed, err := st.ResumeQueue(queue); err != nil || resumed {
+		return fmt.Errorf("resume after the pause ran out: %v, %v", resumed, err)
+	}
+	if _, err := claim(st, queue); err != nil {
+		return err
+	}
+
+	if err := st.PauseQueue(store.QueuePause{Queue: queue}); err != nil {
+		return fmt.Errorf("pause: %w", err)
+	}
+	if resumed, err := st.ResumeQueue(queue); err != nil || !resumed {
+		return fmt.Errorf("resume: %v, %v", resumed, err)
+	}
+	return nil
+}
+
+func checkSchedules(st store.Store, queue string) error {
+	due := formatTime(time.Now().Add(-time.Minute))
+	sched := store.Schedule{
+		Id:         queue + "-schedule",
+		Queue:      queue,
+		Cron:       "* * * * *",
+		Command:    "echo scheduled",
+		MaxRetries: sql.NullInt64{Int64: 2, Valid: true},
+		CatchUp:    "once",
+		NextRunAt:  due,
+	}
+	if err := st.AddSchedule(sched); err != nil {
+		return fmt.Errorf("add: %w", err)
+	}
+	defer st.DeleteSchedule(sched.Id)
+
+	got, err := st.GetSchedule(sched.Id)
+	if err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+	if got.Queue != queue || got.Cron != sched.Cron || got.MaxRetries != sched.MaxRetries || got.TimeoutSeconds.Valid ||
+		got.Paused || got.NextRunAt != due || got.LastRunAt.Valid || got.CreatedAt == "" {
+		return fmt.Errorf("stored schedule is %+v", got)
+	}
+	all, err := st.Schedules()
+	if err != nil {
+		return fmt.Errorf("schedules: %w", err)
+	}
+	if !slices.ContainsFunc(all, func(s store.Schedule) bool { return s.Id == sched.Id }) {
+		return errors.New("schedules doesn't list the new schedule")
+	}
+
+	isDue := func() (bool, error) {
+		schedules, err := st.DueSchedules(formatTime(time.Now()))
+		return slices.ContainsFunc(schedules, func(s store.Schedule) bool { return s.Id == sched.Id }), err
+	}
+	if ok, err := isDue(); err != nil || !ok {
+		return fmt.Errorf("due schedules: listed %v, %v", ok, err)
+	}
+
+	// Only the first of two processes that saw the same Next_run_at enqueues
+	next := formatTime(time.Now().Add(time.Minute))
+	job := newJob(queue, 1, 0)
+	job.ScheduleId = sql.NullString{String: sched.Id, Valid: true}
+	job.ScheduledFor = sql.NullString{String: due, Valid: true}
+	for i, want := range []bool{true, false} {
+		job.Id = fmt.Sprintf("%s-%d", queue, i)
+		advanced, err := st.AdvanceSchedule(got, next, job.ScheduledFor, []store.NewJob{job})
+		if err != nil {
+			return fmt.Errorf("advance: %w", err)
+		}
+		if advanced != want {
+			return fmt.Errorf("advance %d reported %v, want %v", i+1, advanced, want)
+		}
+	}
+	if n, err := st.CountJobs(store.ListFilter{Queue: queue}); err != nil || n != 1 {
+		return fmt.Errorf("schedule enqueued %d job(s), want 1 (%v)", n, err)
+	}
+	if got, err = st.GetSchedule(sched.Id); err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+	if got.NextRunAt != next || got.LastRunAt.String != due {
+		return fmt.Errorf("advanced schedule runs next at %s and last ran at %s", got.NextRunAt, got.LastRunAt.String)
+	}
+
+	if ok, err := st.PauseSchedule(sched.Id); err != nil || !ok {
+		return fmt.Errorf("pause: %v, %v", ok, err)
+	}
+	if ok, err := st.ResumeSchedule(sched.Id, due); err != nil || !ok {
+		return fmt.Errorf("resume: %v, %v", ok, err)
+	}
+	if ok, err := st.PauseSchedule(sched.Id); err != nil || !ok {
+		return fmt.Errorf("pause: %v, %v", ok, err)
+	}
+	if got, err = st.GetSchedule(sched.Id); err != nil || !got.Paused || got.NextRunAt != due {
+		return fmt.Errorf("paused schedule is %+v (%v)", got, err)
+	}
+	if ok, err := isDue(); err != nil || ok {
+		return fmt.Errorf("due schedules: listed a paused one (%v)", err)
+	}
+	if advanced, err := st.AdvanceSchedule(got, next, sql.NullString{}, nil); err != nil || advanced {
+		return fmt.Errorf("advance a paused schedule: %v, %v", advanced, err)
+	}
+
+	if ok, err := st.DeleteSchedule(sched.Id); err != nil || !ok {
+		return fmt.Errorf("delete: %v, %v", ok, err)
+	}
+	if ok, err := st.DeleteSchedule(sched.Id); err != nil || ok {
+		return fmt.Errorf("delete again: %v, %v", ok, err)
+	}
+	if _, err := st.GetSchedule(sched.Id); !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("get a deleted schedule: got %v, want sql.ErrNoRows", err)
+	}
+	return nil
+}
+
+func checkConfig(st store.Store, queue string) error {
+	key := queue + "-key"
+	defer st.UnsetConfig("", key)
+	defer st.UnsetConfig(queue, key)
+
+	expect := func(q, value, source string) error {
+		v, s, err := st.ConfigValue(q, key)
+		if err != nil {
+			return fmt.Errorf("config value: %w", err)
+		}
+		if v != value || s != source {
+			return fmt.Errorf("config value of %q is %q from %q, want %q from %q", q, v, s, value, source)
+		}
+		return nil
+	}
+
+	if err := expect(queue, "", ""); err != nil {
+		return err
+	}
+	steps := []struct {
+		set   func() error
+		value string
+		from  string
+	}{
+		{func() error { return st.SetConfig("", key, "1") }, "1", "global"},
+		{func() error { return st.SetConfig(queue, key, "2") }, "2", "queue"},
+		{func() error { return st.SetConfig(queue, key, "3") }, "3", "queue"},
+		{func() error { return st.UnsetConfig(queue, key) }, "1", "global"},
+	}
+	for _, step := range steps {
+		if err := step.set(); err != nil {
+			return fmt.Errorf("set config: %w", err)
+		}
+		if err := expect(queue, step.value, step.from); err != nil {
+			return err
+		}
+	}
+
+	if err := st.SetConfig(queue, key, "4"); err != nil {
+		return fmt.Errorf("set config: %w", err)
+	}
+	settings, err := st.QueueConfig()
+	if err != nil {
+		return fmt.Errorf("queue config: %w", err)
+	}
+	if !slices.Contains(settings, store.QueueSetting{Queue: queue, Key: key, Value: "4"}) {
+		return errors.New("queue config doesn't list the queue's value")
+	}
+	return nil
+}
+
+func checkListFilters(st store.Store, queue string) error {
+	var batch []store.NewJob
+	for i := 1; i <= 4; i++ {
+		job := newJob(queue, i, i)
+		job.Command = fmt.Sprintf("Backup_%d", i)
+		job.Tags = sql.NullString{String: fmt.Sprintf(`["n%d","all"]`, i%2), Valid: true}
+		batch = append(batch, job)
+	}
+	later := newJob(queue, 5, 0)
+	later.NextRunAt = sql.NullString{String: formatTime(time.Now().Add(time.Hour)), Valid: true}
+	batch = append(batch, later)
+	if err := enqueue(st, batch...); err != nil {
+		return err
+	}
+
+	// A job's worker is cleared when its attempt ends; the attempt keeps it
+	worker := queue + "-worker"
+	job, err := st.Claim(store.ClaimRequest{WorkerId: worker, Queue: queue, Lease: time.Minute})
+	if err != nil {
+		return fmt.Errorf("claim: %w", err)
+	}
+	if _, _, err := st.StartAttempt(job.Id, worker); err != nil {
+		return fmt.Errorf("start attempt: %w", err)
+	}
+	if _, err := st.Complete(job, store.Event{Event: "completed", FromState: "processing", ToState: "completed"}); err != nil {
+		return fmt.Errorf("complete: %w", err)
+	}
+
+	one := 1
+	cases := []struct {
+		name   string
+		filter store.ListFilter
+		want   int
+	}{
+		{"queue", store.ListFilter{}, 5},
+		{"state", store.ListFilter{State: "pending"}, 4},
+		{"scheduled", store.ListFilter{State: "scheduled"}, 1},
+		{"command ignoring case", store.ListFilter{Command: "backup"}, 4},
+		{"command with a wildcard", store.ListFilter{Command: "p_1"}, 1},
+		{"match", store.ListFilter{Match: `^Backup_[12]$`}, 2},
+		{"tag", store.ListFilter{Tags: []string{"n1"}}, 2},
+		{"every tag", store.ListFilter{Tags: []string{"n1", "all"}}, 2},
+		{"created after", store.ListFilter{CreatedAfter: formatTime(time.Now().Add(-time.Hour))}, 5},
+		{"created before", store.ListFilter{CreatedBefore: formatTime(time.Now().Add(-time.Hour))}, 0},
+		{"min attempts", store.ListFilter{MinAttempts: &one}, 0},
+		{"worker", store.ListFilter{WorkerId: worker}, 1},
+		{"another worker", store.ListFilter{WorkerId: "conformance"}, 0},
+	}
+	for _, c := range cases {
+		c.filter.Queue = queue
+		n, err := st.CountJobs(c.filter)
+		if err != nil {
+			return fmt.Errorf("count by %s: %w", c.name, err)
+		}
+		jobs, _, err := st.ListJobs(c.filter)
+		if err != nil {
+			return fmt.Errorf("list by %s: %w", c.name, err)
+		}
+		if n != c.want || len(jobs) != c.want {
+			return fmt.Errorf("filter by %s found %d job(s) and counted %d, want %d", c.name, len(jobs), n, c.want)
+		}
+	}
+	return nil
+}
+
+func checkListPaging(st store.Store, queue string) error {
+	var batch []store.NewJob
+	for i := 0; i < 7; i++ {
+		// Pairs of equal priority, so the cursor has ties to break
+		batch = append(batch, newJob(queue, i, i/2))
+	}
+	if err := enqueue(st, batch...); err != nil {
+		return err
+	}
+
+	all, next, err := st.ListJobs(store.ListFilter{Queue: queue, Sort: "priority", Desc: true})
+	if err != nil {
+		return fmt.Errorf("list: %w", err)
+	}
+	if len(all) != 7 || next != "" {
+		return fmt.Errorf("list returned %d jobs and cursor %q, want 7 and none", len(all), next)
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].Priority > all[i-1].Priority {
+			return errors.New("jobs aren't sorted by priority")
+		}
+	}
+
+	// Walking the pages must give the same jobs in the same order
+	var paged []string
+	after := ""
+	for pages := 0; ; pages++ {
+		if pages > 7 {
+			return errors.New("paging doesn't end")
+		}
+		page, next, err := st.ListJobs(store.ListFilter{Queue: queue, Sort: "priority", Desc: true, Limit: 3, After: after})
+		if err != nil {
+			return fmt.Errorf("list page: %w", err)
+		}
+		for _, j := range page {
+			paged = append(paged, j.Id)
+		}
+		if next == "" {
+			break
+		}
+		after = next
+	}
+	var want []string
+	for _, j := range all {
+		want = append(want, j.Id)
+	}
+	if !slices.Equal(paged, want) {
+		return fmt.Errorf("pages gave %s, want %s", strings.Join(paged, " "), strings.Join(want, " "))
+	}
+
+	page, _, err := st.ListJobs(store.ListFilter{Queue: queue, Sort: "priority", Desc: true, Limit: 2, Offset: 5})
+	if err != nil {
+		return fmt.Errorf("list with offset: %w", err)
+	}
+	if len(page) != 2 || page[0].Id != want[5] {
+		return errors.New("offset page doesn't match the full list")
+	}
+
+	if _, _, err := st.ListJobs(store.ListFilter{Queue: queue, Sort: "id", After: after}); !errors.Is(err, store.ErrInvalidCursor) {
+		return fmt.Errorf("cursor used with another sort returned %v, want ErrInvalidCursor", err)
+	}
+	if _, _, err := st.ListJobs(store.ListFilter{Queue: queue, After: "nonsense"}); !errors.Is(err, store.ErrInvalidCursor) {
+		return fmt.Errorf("malformed cursor returned %v, want ErrInvalidCursor", err)
+	}
+	return nil
+}
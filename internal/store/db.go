@@ -0,0 +1,122 @@
+package store
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// DB is a connection pool that takes queries with ? placeholders on every
+// database. PostgreSQL numbers its placeholders ($1, $2...), so for it
+// each query is rewritten before it runs.
+type DB struct {
+	conn   *sql.DB
+	rebind bool
+}
+
+// Tx is a transaction started by DB.Begin.
+type Tx struct {
+	tx     *sql.Tx
+	rebind bool
+}
+
+// Execer is a *DB or *Tx.
+type Execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func (db *DB) query(q string) string {
+	if db.rebind {
+		return rebind(q)
+	}
+	return q
+}
+
+func (db *DB) Exec(query string, args ...any) (sql.Result, error) {
+	return db.conn.Exec(db.query(query), args...)
+}
+
+func (db *DB) Query(query string, args ...any) (*sql.Rows, error) {
+	return db.conn.Query(db.query(query), args...)
+}
+
+func (db *DB) QueryRow(query string, args ...any) *sql.Row {
+	return db.conn.QueryRow(db.query(query), args...)
+}
+
+func (db *DB) Begin() (*Tx, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{tx: tx, rebind: db.rebind}, nil
+}
+
+func (db *DB) Close() error {
+	return db.conn.Close()
+}
+
+func (tx *Tx) query(q string) string {
+	if tx.rebind {
+		return rebind(q)
+	}
+	return q
+}
+
+func (tx *Tx) Exec(query string, args ...any) (sql.Result, error) {
+	return tx.tx.Exec(tx.query(query), args...)
+}
+
+func (tx *Tx) Query(query string, args ...any) (*sql.Rows, error) {
+	return tx.tx.Query(tx.query(query), args...)
+}
+
+func (tx *Tx) QueryRow(query string, args ...any) *sql.Row {
+	return tx.tx.QueryRow(tx.query(query), args...)
+}
+
+func (tx *Tx) Commit() error {
+	return tx.tx.Commit()
+}
+
+func (tx *Tx) Rollback() error {
+	return tx.tx.Rollback()
+}
+
+// rebind numbers the ? placeholders of query. Question marks inside
+// string literals, quoted identifiers and comments are left alone.
+func rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		case c == '\'' || c == '"':
+			// Copy through the closing quote; a doubled quote is an
+			// escaped one and just starts the next quoted run
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 1
+			continue
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end])
+			i += end - 1
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,95 @@
+package store
+
+import "database/sql"
+
+// Event is one entry of a job's history in job_events. Rows are only ever
+// appended. FromState and ToState are empty for events that don't change
+// the state, such as a cancel request for a running job.
+type Event struct {
+	At        string
+	Event     string
+	FromState string
+	ToState   string
+	Attempt   int
+	WorkerId  string
+	Actor     string
+	Detail    string
+}
+
+// nullIfEmpty stores "" and 0 as NULL.
+func nullIfEmpty[T comparable](v T) any {
+	var zero T
+	if v == zero {
+		return nil
+	}
+	return v
+}
+
+// RecordEvent appends e to the history of job jobId. At defaults to now.
+func RecordEvent(db Execer, jobId string, e Event) error {
+	if e.At == "" {
+		e.At = now()
+	}
+	_, err := db.Exec(`
+		INSERT INTO job_events (JobId, At, Event, From_state, To_state, Attempt, WorkerId, Actor, Detail)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, jobId, e.At, e.Event, nullIfEmpty(e.FromState), nullIfEmpty(e.ToState),
+		nullIfEmpty(e.Attempt), nullIfEmpty(e.WorkerId), nullIfEmpty(e.Actor), nullIfEmpty(e.Detail))
+	return err
+}
+
+// Transition runs an UPDATE that moves job jobId to another state and, if
+// it matched the job, records e in the same transaction. It reports
+// whether the job was changed; an UPDATE guarded by a claim token or the
+// current state may legitimately match nothing.
+func (db *DB) Transition(jobId string, e Event, query string, args ...any) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	if err := RecordEvent(tx, jobId, e); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (s *sqlStore) JobEvents(jobId string) ([]Event, error) {
+	rows, err := s.db.Query(`
+		SELECT At, Event, From_state, To_state, Attempt, WorkerId, Actor, Detail
+		FROM job_events
+		WHERE JobId = ?
+		ORDER BY Id
+	`, jobId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		var from, to, workerId, actor, detail sql.NullString
+		var attempt sql.NullInt64
+		if err := rows.Scan(&e.At, &e.Event, &from, &to, &attempt, &workerId, &actor, &detail); err != nil {
+			return nil, err
+		}
+		e.FromState = from.String
+		e.ToState = to.String
+		e.Attempt = int(attempt.Int64)
+		e.WorkerId = workerId.String
+		e.Actor = actor.String
+		e.Detail = detail.String
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
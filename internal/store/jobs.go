@@ -0,0 +1,302 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func (s *sqlStore) Enqueue(jobs []NewJob) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, job := range jobs {
+		if err := insertJob(tx, job); err != nil {
+			return fmt.Errorf("job %s: %w", job.Id, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// insertJob writes a job and starts its history, as part of a larger
+// transaction such as a schedule's.
+func insertJob(tx Execer, job NewJob) error {
+	_, err := tx.Exec(`
+		INSERT INTO jobs (
+			Id, Queue, Command, State, Attempts, Max_retries, Priority, Timeout_seconds, Env, Tags,
+			Backoff_strategy, Backoff_base_seconds, Backoff_max_seconds, Retry_on, Fail_fast_on,
+			Schedule_id, Scheduled_for, Type, Payload, WorkerId, Next_run_at, Created_at, Updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULL, ?, ?, ?)
+	`,
+		job.Id,
+		job.Queue,
+		job.Command,
+		job.State,
+		job.Attempts,
+		job.MaxRetries,
+		job.Priority,
+		job.TimeoutSeconds,
+		job.Env,
+		job.Tags,
+		job.BackoffStrategy,
+		job.BackoffBaseSeconds,
+		job.BackoffMaxSeconds,
+		job.RetryOn,
+		job.FailFastOn,
+		job.ScheduleId,
+		job.ScheduledFor,
+		job.Type,
+		job.Payload,
+		job.NextRunAt,
+		job.CreatedAt,
+		job.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	event := Event{Event: "enqueued", ToState: job.State, Actor: job.Actor, Detail: "queue " + job.Queue}
+	if job.ScheduleId.Valid {
+		event.Actor = "schedule " + job.ScheduleId.String
+		event.Detail += ", occurrence " + job.ScheduledFor.String
+	} else if job.NextRunAt.String > job.CreatedAt {
+		event.Detail += ", not before " + job.NextRunAt.String
+	}
+	return RecordEvent(tx, job.Id, event)
+}
+
+// Claim picks the highest priority ready job, oldest first among equals,
+// skipping paused queues. The pick and the state change happen in a
+// single UPDATE, so when several workers race for the same row only one of
+// them gets it back.
+func (s *sqlStore) Claim(r ClaimRequest) (*ClaimedJob, error) {
+	job := &ClaimedJob{ClaimToken: uuid.NewString()}
+	nowStr := now()
+	leaseExpiresAt := formatTime(time.Now().Add(r.Lease))
+
+	// A job without a command needs a handler for its type
+	runnable := "COALESCE(Command, '') <> ''"
+	args := []any{r.WorkerId, job.ClaimToken, leaseExpiresAt, nowStr, nowStr, r.Queue, r.Queue}
+	if len(r.Types) > 0 {
+		runnable = "(" + runnable + " OR Type IN (?" + strings.Repeat(", ?", len(r.Types)-1) + "))"
+		for _, t := range r.Types {
+			args = append(args, t)
+		}
+	}
+	args = append(args, nowStr)
+
+	// Created_at only has seconds, so the seq column decides between jobs
+	// enqueued in the same second
+	orderBy := "Priority DESC, Created_at, " + s.d.seq
+	if r.PriorityAging > 0 {
+		orderBy = `Priority + (` + s.d.epoch("?") + ` - ` + s.d.epoch("COALESCE(Next_run_at, Created_at)") + `) / ? DESC, Created_at, ` + s.d.seq
+		args = append(args, nowStr, r.PriorityAging)
+	}
+
+	err := s.db.QueryRow(`
+		UPDATE jobs
+		SET State='processing', WorkerId=?, Claim_token=?, Lease_expires_at=?, Updated_at=?
+		WHERE Id = (
+			SELECT Id FROM jobs
+			WHERE State='pending'
+			AND (Next_run_at IS NULL OR Next_run_at <= ?)
+			AND (? = '' OR Queue = ?)
+			AND `+runnable+`
+			AND Queue NOT IN (
+				SELECT Queue FROM paused_queues
+				WHERE Resume_at IS NULL OR Resume_at > ?
+			)
+			ORDER BY `+orderBy+`
+			LIMIT 1
+			`+s.d.skipLocked+`
+		)
+		AND State='pending'
+		RETURNING Id, Queue, Command, Attempts, Max_retries, Priority, Timeout_seconds, Env,
+			Backoff_strategy, Backoff_base_seconds, Backoff_max_seconds, Last_backoff_seconds,
+			Retry_on, Fail_fast_on, Type, Payload
+	`, args...).Scan(
+		&job.Id, &job.Queue, &job.Command, &job.Attempts, &job.MaxRetries, &job.Priority, &job.TimeoutSeconds, &job.Env,
+		&job.BackoffStrategy, &job.BackoffBaseSeconds, &job.BackoffMaxSeconds, &job.LastBackoffSeconds,
+		&job.RetryOn, &job.FailFastOn, &job.Type, &job.Payload)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoJob
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (s *sqlStore) Complete(job *ClaimedJob, e Event) (bool, error) {
+	// STATE: processing → completed
+	return s.db.Transition(job.Id, e, `
+		UPDATE jobs
+		SET State='completed', Failure_reason=NULL, WorkerId=NULL, Claim_token=NULL, Lease_expires_at=NULL, Updated_at=?
+		WHERE Id=? AND Claim_token=?
+	`, now(), job.Id, job.ClaimToken)
+}
+
+// Fail records a failed attempt. A job with retries left goes through
+// failed back to pending, in two steps so its history shows both.
+func (s *sqlStore) Fail(job *ClaimedJob, f Failure) (bool, error) {
+	if f.Dead {
+		// STATE: processing → dead
+		return s.db.Transition(job.Id, f.Event, `
+			UPDATE jobs
+			SET State='dead', Attempts=?, Failure_reason=?, WorkerId=NULL, Claim_token=NULL, Lease_expires_at=NULL, Updated_at=?
+			WHERE Id=? AND Claim_token=?
+		`, f.Attempts, f.Reason, now(), job.Id, job.ClaimToken)
+	}
+
+	// STATE: processing → failed
+	ok, err := s.db.Transition(job.Id, f.Event, `
+		UPDATE jobs
+		SET State='failed',
+			Attempts=?,
+			Failure_reason=?,
+			WorkerId=NULL,
+			Claim_token=NULL,
+			Lease_expires_at=NULL,
+			Next_run_at=?,
+			Last_backoff_seconds=?,
+			Updated_at=?
+		WHERE Id=? AND Claim_token=?
+	`, f.Attempts, f.Reason, f.NextRunAt, f.BackoffSeconds, now(), job.Id, job.ClaimToken)
+	if !ok || err != nil {
+		return ok, err
+	}
+
+	// STATE: failed → pending, to be picked up after the backoff
+	return s.db.Transition(job.Id, f.Retry, `
+		UPDATE jobs SET State='pending' WHERE Id=? AND State='failed'
+	`, job.Id)
+}
+
+func (s *sqlStore) Requeue(job *ClaimedJob, r Requeue) (bool, error) {
+	// STATE: processing → pending
+	return s.db.Transition(job.Id, r.Event, `
+		UPDATE jobs
+		SET State='pending',
+			Failure_reason=?,
+			WorkerId=NULL,
+			Claim_token=NULL,
+			Lease_expires_at=NULL,
+			Next_run_at=?,
+			Last_backoff_seconds=COALESCE(?, Last_backoff_seconds),
+			Updated_at=?
+		WHERE Id=? AND Claim_token=?
+	`, r.Reason, r.NextRunAt, r.BackoffSeconds, now(), job.Id, job.ClaimToken)
+}
+
+func (s *sqlStore) Cancelled(job *ClaimedJob, e Event) (bool, error) {
+	// STATE: processing → cancelled
+	return s.db.Transition(job.Id, e, `
+		UPDATE jobs
+		SET State='cancelled',
+			Cancelled_at=?,
+			WorkerId=NULL,
+			Claim_token=NULL,
+			Lease_expires_at=NULL,
+			Updated_at=?
+		WHERE Id=? AND Claim_token=?
+	`, now(), now(), job.Id, job.ClaimToken)
+}
+
+func (s *sqlStore) Cancel(jobId, fromState string, c Cancellation) (bool, error) {
+	nowStr := now()
+
+	// STATE: pending/failed → cancelled
+	return s.db.Transition(jobId,
+		Event{Event: "cancelled", FromState: fromState, ToState: "cancelled", Actor: c.By, Detail: c.Reason}, `
+		UPDATE jobs
+		SET State='cancelled', Cancelled_at=?, Cancelled_by=?, Cancel_reason=?, Updated_at=?
+		WHERE Id=? AND State=? AND State IN ('pending', 'failed')
+	`, nowStr, c.By, nullIfEmpty(c.Reason), nowStr, jobId, fromState)
+}
+
+func (s *sqlStore) RequestCancel(jobId string, c Cancellation) (bool, error) {
+	nowStr := now()
+
+	return s.db.Transition(jobId,
+		Event{Event: "cancel_requested", Actor: c.By, Detail: c.Reason}, `
+		UPDATE jobs
+		SET Cancel_requested_at=?, Cancelled_by=?, Cancel_reason=?, Updated_at=?
+		WHERE Id=? AND State='processing' AND Cancel_requested_at IS NULL
+	`, nowStr, c.By, nullIfEmpty(c.Reason), nowStr, jobId)
+}
+
+func (s *sqlStore) RetryDead(jobId string, maxRetries sql.NullInt64, actor string) (bool, error) {
+	detail := "from the DLQ"
+	if maxRetries.Valid {
+		detail += fmt.Sprintf(", max_retries = %d", maxRetries.Int64)
+	}
+
+	// STATE: dead → pending
+	return s.db.Transition(jobId,
+		Event{Event: "retried", FromState: "dead", ToState: "pending", Actor: actor, Detail: detail}, `
+		UPDATE jobs
+		SET
+			State = 'pending',
+			Attempts = 0,
+			Max_retries = COALESCE(?, Max_retries),
+			Next_run_at = NULL,
+			WorkerId = NULL,
+			Claim_token = NULL,
+			Lease_expires_at = NULL,
+			Last_backoff_seconds = NULL,
+			Updated_at = ?
+		WHERE Id = ? AND State = 'dead'
+	`, maxRetries, now(), jobId)
+}
+
+func (s *sqlStore) CancelRequested(jobId, workerId string) (bool, error) {
+	var requested int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM jobs
+		WHERE Id=? AND WorkerId=? AND State='processing' AND Cancel_requested_at IS NOT NULL
+	`, jobId, workerId).Scan(&requested)
+	return requested > 0, err
+}
+
+func (s *sqlStore) RecordEvent(jobId string, e Event) error {
+	return RecordEvent(s.db, jobId, e)
+}
+
+// jobColumns are the columns scanJob expects, in order, but for the
+// dialect's seq column, which comes last.
+const jobColumns = `
+	Id, Queue, Command, State, Attempts, Max_retries, Priority,
+	Timeout_seconds, Backoff_strategy, Backoff_base_seconds, Backoff_max_seconds,
+	Retry_on, Fail_fast_on, Env, Tags, Created_at, Updated_at,
+	Next_run_at, WorkerId, Failure_reason,
+	Cancel_requested_at, Cancelled_at, Cancelled_by, Cancel_reason,
+	Type, Payload`
+
+// rowScanner is a *sql.Row or *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanJob(row rowScanner) (Job, error) {
+	var j Job
+	err := row.Scan(&j.Id, &j.Queue, &j.Command, &j.State, &j.Attempts, &j.MaxRetries, &j.Priority,
+		&j.TimeoutSeconds, &j.BackoffStrategy, &j.BackoffBaseSeconds, &j.BackoffMaxSeconds,
+		&j.RetryOn, &j.FailFastOn, &j.Env, &j.Tags, &j.CreatedAt, &j.UpdatedAt,
+		&j.NextRunAt, &j.WorkerId, &j.FailureReason,
+		&j.CancelRequestedAt, &j.CancelledAt, &j.CancelledBy, &j.CancelReason,
+		&j.Type, &j.Payload,
+		&j.Seq)
+	return j, err
+}
+
+func (s *sqlStore) GetJob(id string) (Job, error) {
+	return scanJob(s.db.QueryRow(`SELECT `+jobColumns+`, `+s.d.seq+` FROM jobs WHERE Id = ?`, id))
+}
@@ -0,0 +1,268 @@
+package store
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ListFilter selects jobs for ListJobs and CountJobs. Conditions combine
+// with AND; zero values don't filter.
+type ListFilter struct {
+	// State "scheduled" means pending jobs that aren't due yet
+	State string
+	Queue string
+	Type  string
+
+	// WorkerId ran the job's latest attempt, whether it has ended or not
+	WorkerId string
+
+	// Command contains this text, ignoring case
+	Command string
+
+	// Command matches this regular expression
+	Match string
+
+	// Tags the job must all have
+	Tags []string
+
+	// RFC3339 times; After is inclusive, Before exclusive
+	CreatedAfter  string
+	CreatedBefore string
+	UpdatedAfter  string
+	UpdatedBefore string
+
+	MinAttempts *int
+	MaxAttempts *int
+
+	// Sort is one of SortFields, "created_at" when empty
+	Sort string
+	Desc bool
+
+	// Limit of 0 returns every job. After is the cursor of the previous
+	// page; it can't be combined with Offset.
+	Limit  int
+	Offset int
+	After  string
+}
+
+// sortColumn is a field jobs can be sorted by. Nullable columns sort as ""
+// so the page cursor can compare them.
+type sortColumn struct {
+	expr  string
+	value func(job Job) any
+}
+
+var sortColumns = map[string]sortColumn{
+	"id":          {"Id", func(j Job) any { return j.Id }},
+	"queue":       {"Queue", func(j Job) any { return j.Queue }},
+	"command":     {"Command", func(j Job) any { return j.Command }},
+	"state":       {"State", func(j Job) any { return j.State }},
+	"attempts":    {"Attempts", func(j Job) any { return j.Attempts }},
+	"max_retries": {"Max_retries", func(j Job) any { return j.MaxRetries }},
+	"priority":    {"Priority", func(j Job) any { return j.Priority }},
+	"created_at":  {"Created_at", func(j Job) any { return j.CreatedAt }},
+	"updated_at":  {"Updated_at", func(j Job) any { return j.UpdatedAt }},
+	"next_run_at": {"COALESCE(Next_run_at, '')", func(j Job) any { return j.NextRunAt.String }},
+	"worker_id":   {"COALESCE(WorkerId, '')", func(j Job) any { return j.WorkerId.String }},
+}
+
+// SortFields are the fields ListJobs can sort by, alphabetically.
+func SortFields() []string {
+	return slices.Sorted(maps.Keys(sortColumns))
+}
+
+// ErrInvalidCursor is returned for an After that isn't a cursor ListJobs
+// made, or was made for another sort.
+var ErrInvalidCursor = errors.New("invalid page cursor")
+
+// cursor marks where a page ended: the sort value and seq of its last job.
+// The next page starts right after it.
+type cursor struct {
+	Sort  string `json:"s"`
+	Value any    `json:"v"`
+	Seq   int64  `json:"r"`
+}
+
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&c)
+	}
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	// Numbers come back as int64 so that both databases compare them with
+	// integer columns
+	if n, ok := c.Value.(json.Number); ok {
+		if c.Value, err = n.Int64(); err != nil {
+			return c, ErrInvalidCursor
+		}
+	}
+	return c, nil
+}
+
+// where builds the conditions of f.
+func (s *sqlStore) where(f ListFilter) ([]string, []any) {
+	var conditions []string
+	var args []any
+
+	if f.State == "scheduled" {
+		conditions = append(conditions, "State = 'pending' AND Next_run_at > ?")
+		args = append(args, now())
+	} else if f.State != "" {
+		conditions = append(conditions, "State = ?")
+		args = append(args, f.State)
+	}
+	if f.Queue != "" {
+		conditions = append(conditions, "Queue = ?")
+		args = append(args, f.Queue)
+	}
+	if f.Type != "" {
+		conditions = append(conditions, "Type = ?")
+		args = append(args, f.Type)
+	}
+	if f.Command != "" {
+		conditions = append(conditions, s.d.contains)
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(f.Command)
+		args = append(args, "%"+escaped+"%")
+	}
+	if f.Match != "" {
+		conditions = append(conditions, s.d.matches)
+		args = append(args, f.Match)
+	}
+	if f.WorkerId != "" {
+		// A job's WorkerId is cleared when the attempt ends, so the last
+		// claim is the worker of its latest attempt
+		conditions = append(conditions, "(SELECT a.WorkerId FROM job_attempts a WHERE a.JobId = jobs.Id ORDER BY a.Attempt DESC, a.Id DESC LIMIT 1) = ?")
+		args = append(args, f.WorkerId)
+	}
+	for _, tag := range f.Tags {
+		conditions = append(conditions, s.d.hasTag)
+		args = append(args, tag)
+	}
+	for _, t := range []struct {
+		value string
+		cond  string
+	}{
+		{f.CreatedAfter, "Created_at >= ?"},
+		{f.CreatedBefore, "Created_at < ?"},
+		{f.UpdatedAfter, "Updated_at >= ?"},
+		{f.UpdatedBefore, "Updated_at < ?"},
+	} {
+		if t.value != "" {
+			conditions = append(conditions, t.cond)
+			args = append(args, t.value)
+		}
+	}
+	if f.MinAttempts != nil {
+		conditions = append(conditions, "Attempts >= ?")
+		args = append(args, *f.MinAttempts)
+	}
+	if f.MaxAttempts != nil {
+		conditions = append(conditions, "Attempts <= ?")
+		args = append(args, *f.MaxAttempts)
+	}
+	return conditions, args
+}
+
+func (s *sqlStore) CountJobs(f ListFilter) (int, error) {
+	conditions, args := s.where(f)
+	query := `SELECT COUNT(*) FROM jobs`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	var n int
+	err := s.db.QueryRow(query, args...).Scan(&n)
+	return n, err
+}
+
+func (s *sqlStore) ListJobs(f ListFilter) ([]Job, string, error) {
+	if f.Sort == "" {
+		f.Sort = "created_at"
+	}
+	column, ok := sortColumns[f.Sort]
+	if !ok {
+		return nil, "", fmt.Errorf("cannot sort by %q", f.Sort)
+	}
+	if f.After != "" && f.Offset > 0 {
+		return nil, "", errors.New("a page cursor can't be combined with an offset")
+	}
+
+	// The seq column breaks ties so every job has a fixed place in the
+	// order, which cursors rely on. It also keeps jobs created in the same
+	// second in the order they were added.
+	dir, cmp := "ASC", ">"
+	if f.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	sortSpec := f.Sort + ":" + strings.ToLower(dir)
+
+	conditions, args := s.where(f)
+	if f.After != "" {
+		c, err := decodeCursor(f.After)
+		if err != nil {
+			return nil, "", err
+		}
+		if c.Sort != sortSpec {
+			return nil, "", fmt.Errorf("%w: it is for sort %s", ErrInvalidCursor, c.Sort)
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))",
+			column.expr, cmp, column.expr, s.d.seq, cmp))
+		args = append(args, c.Value, c.Value, c.Seq)
+	}
+
+	query := `SELECT ` + jobColumns + `, ` + s.d.seq + ` FROM jobs`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s, %s %s", column.expr, dir, s.d.seq, dir)
+
+	// Fetch one extra row to know whether there is a next page
+	if f.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, f.Limit+1, f.Offset)
+	} else if f.Offset > 0 {
+		query += " " + s.d.offsetOnly
+		args = append(args, f.Offset)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	jobs := []Job{}
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if f.Limit > 0 && len(jobs) > f.Limit {
+		jobs = jobs[:f.Limit]
+		last := jobs[len(jobs)-1]
+		next = cursor{Sort: sortSpec, Value: column.value(last), Seq: last.Seq}.encode()
+	}
+	return jobs, next, nil
+}
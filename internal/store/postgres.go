@@ -0,0 +1,26 @@
+package store
+
+import "database/sql"
+
+// NewPostgres returns the Store of a PostgreSQL database opened with
+// db.OpenPostgres. Workers on any number of machines can share it: a
+// claim locks the job it picks with FOR UPDATE SKIP LOCKED, so concurrent
+// claims neither wait for each other nor get the same job.
+//
+// "list -m" uses PostgreSQL's POSIX regular expressions, which accept
+// nearly the same patterns as Go's.
+func NewPostgres(conn *sql.DB) Store {
+	return newSQLStore(conn, dialect{
+		rebind:     true,
+		seq:        "Seq",
+		forUpdate:  "FOR UPDATE",
+		skipLocked: "FOR UPDATE SKIP LOCKED",
+		epoch: func(expr string) string {
+			return "CAST(EXTRACT(EPOCH FROM CAST(" + expr + " AS timestamptz)) AS BIGINT)"
+		},
+		contains:   `Command ILIKE ? ESCAPE '\'`,
+		matches:    "Command ~ ?",
+		hasTag:     "EXISTS (SELECT 1 FROM jsonb_array_elements_text(CAST(jobs.Tags AS jsonb)) AS t(value) WHERE t.value = ?)",
+		offsetOnly: "OFFSET ?",
+	})
+}
@@ -0,0 +1,82 @@
+package store
+
+import "database/sql"
+
+func (s *sqlStore) QueueCounts() ([]QueueCount, error) {
+	rows, err := s.db.Query(`
+		SELECT Queue, State, COUNT(*)
+		FROM jobs
+		GROUP BY Queue, State
+		ORDER BY Queue, State
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []QueueCount
+	for rows.Next() {
+		var c QueueCount
+		if err := rows.Scan(&c.Queue, &c.State, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+func (s *sqlStore) PauseQueue(p QueuePause) error {
+	if p.PausedAt == "" {
+		p.PausedAt = now()
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO paused_queues (Queue, Paused_at, Paused_by, Reason, Resume_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (Queue) DO UPDATE SET
+			Paused_at = excluded.Paused_at,
+			Paused_by = excluded.Paused_by,
+			Reason = excluded.Reason,
+			Resume_at = excluded.Resume_at
+	`, p.Queue, p.PausedAt, nullIfEmpty(p.PausedBy), nullIfEmpty(p.Reason), nullIfEmpty(p.ResumeAt))
+	return err
+}
+
+func (s *sqlStore) ResumeQueue(queue string) (bool, error) {
+	res, err := s.db.Exec(`
+		DELETE FROM paused_queues
+		WHERE Queue = ? AND (Resume_at IS NULL OR Resume_at > ?)
+	`, queue, now())
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// PausedQueues leaves out pauses whose ResumeAt has passed; nothing deletes
+// their rows, claims just stop skipping the queue then.
+func (s *sqlStore) PausedQueues() (map[string]QueuePause, error) {
+	rows, err := s.db.Query(`
+		SELECT Queue, Paused_at, Paused_by, Reason, Resume_at
+		FROM paused_queues
+		WHERE Resume_at IS NULL OR Resume_at > ?
+	`, now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	paused := map[string]QueuePause{}
+	for rows.Next() {
+		var p QueuePause
+		var pausedBy, reason, resumeAt sql.NullString
+		if err := rows.Scan(&p.Queue, &p.PausedAt, &pausedBy, &reason, &resumeAt); err != nil {
+			return nil, err
+		}
+		p.PausedBy = pausedBy.String
+		p.Reason = reason.String
+		p.ResumeAt = resumeAt.String
+		paused[p.Queue] = p
+	}
+	return paused, rows.Err()
+}
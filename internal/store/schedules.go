@@ -0,0 +1,117 @@
+package store
+
+import "database/sql"
+
+const scheduleColumns = `Id, Queue, Cron, Command, Max_retries, Timeout_seconds, Catch_up,
+	Paused, Next_run_at, Last_run_at, Created_at`
+
+func scanSchedule(row rowScanner) (Schedule, error) {
+	var s Schedule
+	var createdAt sql.NullString
+	err := row.Scan(&s.Id, &s.Queue, &s.Cron, &s.Command, &s.MaxRetries, &s.TimeoutSeconds, &s.CatchUp,
+		&s.Paused, &s.NextRunAt, &s.LastRunAt, &createdAt)
+	s.CreatedAt = createdAt.String
+	return s, err
+}
+
+func (s *sqlStore) querySchedules(query string, args ...any) ([]Schedule, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []Schedule
+	for rows.Next() {
+		sched, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, sched)
+	}
+	return schedules, rows.Err()
+}
+
+func (s *sqlStore) AddSchedule(sched Schedule) error {
+	if sched.CreatedAt == "" {
+		sched.CreatedAt = now()
+	}
+	paused := 0
+	if sched.Paused {
+		paused = 1
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO schedules (`+scheduleColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, sched.Id, sched.Queue, sched.Cron, sched.Command, sched.MaxRetries, sched.TimeoutSeconds, sched.CatchUp,
+		paused, sched.NextRunAt, sched.LastRunAt, sched.CreatedAt)
+	return err
+}
+
+func (s *sqlStore) Schedules() ([]Schedule, error) {
+	return s.querySchedules(`SELECT ` + scheduleColumns + ` FROM schedules ORDER BY Created_at`)
+}
+
+func (s *sqlStore) GetSchedule(id string) (Schedule, error) {
+	return scanSchedule(s.db.QueryRow(`SELECT `+scheduleColumns+` FROM schedules WHERE Id = ?`, id))
+}
+
+func (s *sqlStore) PauseSchedule(id string) (bool, error) {
+	return s.changeSchedule(`UPDATE schedules SET Paused = 1 WHERE Id = ?`, id)
+}
+
+func (s *sqlStore) ResumeSchedule(id, nextRunAt string) (bool, error) {
+	return s.changeSchedule(`UPDATE schedules SET Paused = 0, Next_run_at = ? WHERE Id = ?`, nextRunAt, id)
+}
+
+func (s *sqlStore) DeleteSchedule(id string) (bool, error) {
+	return s.changeSchedule(`DELETE FROM schedules WHERE Id = ?`, id)
+}
+
+// changeSchedule runs query and reports whether it matched a schedule.
+func (s *sqlStore) changeSchedule(query string, args ...any) (bool, error) {
+	res, err := s.db.Exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (s *sqlStore) DueSchedules(at string) ([]Schedule, error) {
+	return s.querySchedules(`
+		SELECT `+scheduleColumns+`
+		FROM schedules
+		WHERE Paused = 0 AND Next_run_at <= ?
+	`, at)
+}
+
+// AdvanceSchedule is a compare-and-swap of Next_run_at in the same
+// transaction as the inserts, so a process that lost the race to another
+// enqueues nothing.
+func (s *sqlStore) AdvanceSchedule(sched Schedule, nextRunAt string, lastRunAt sql.NullString, jobs []NewJob) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		UPDATE schedules
+		SET Next_run_at = ?, Last_run_at = COALESCE(?, Last_run_at)
+		WHERE Id = ? AND Next_run_at = ? AND Paused = 0
+	`, nextRunAt, lastRunAt, sched.Id, sched.NextRunAt)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	for _, job := range jobs {
+		if err := insertJob(tx, job); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,46 @@
+package store
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"regexp"
+	"sync"
+
+	"modernc.org/sqlite"
+)
+
+// NewSQLite returns the Store of an SQLite database opened with db.Open.
+// Claims are safe without row locks because SQLite runs one writer at a
+// time and a claim is a single UPDATE.
+func NewSQLite(conn *sql.DB) Store {
+	return newSQLStore(conn, dialect{
+		seq:        "rowid",
+		epoch:      func(expr string) string { return "unixepoch(" + expr + ")" },
+		contains:   `Command LIKE ? ESCAPE '\'`,
+		matches:    "Command REGEXP ?",
+		hasTag:     "EXISTS (SELECT 1 FROM json_each(jobs.Tags) WHERE value = ?)",
+		offsetOnly: "LIMIT -1 OFFSET ?",
+	})
+}
+
+// regexpCache keeps the patterns compiled by the REGEXP function, which
+// SQLite calls once per row.
+var regexpCache sync.Map
+
+func init() {
+	// SQLite parses "x REGEXP y" but leaves the function to the application
+	sqlite.MustRegisterDeterministicScalarFunction("regexp", 2, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		pattern, _ := args[0].(string)
+		text, _ := args[1].(string)
+
+		re, ok := regexpCache.Load(pattern)
+		if !ok {
+			compiled, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, err
+			}
+			re, _ = regexpCache.LoadOrStore(pattern, compiled)
+		}
+		return re.(*regexp.Regexp).MatchString(text), nil
+	})
+}
@@ -0,0 +1,49 @@
+package store
+
+import "database/sql"
+
+// dialect is where SQLite and PostgreSQL need different SQL. Everything
+// else is shared by sqlStore.
+type dialect struct {
+	// rebind numbers the ? placeholders
+	rebind bool
+
+	// seq is the column that orders jobs by insertion
+	seq string
+
+	// forUpdate locks the rows a SELECT returns until the transaction
+	// ends; SQLite has no row locks, and its writers never run at once
+	forUpdate string
+
+	// skipLocked makes a claim pass over jobs another worker is claiming
+	// instead of waiting for it
+	skipLocked string
+
+	// epoch converts an RFC3339 text expression to Unix seconds
+	epoch func(expr string) string
+
+	// Command conditions for "list -c" (case-insensitive substring) and
+	// "list -m" (regular expression)
+	contains string
+	matches  string
+
+	// hasTag is true for jobs whose Tags array holds ?
+	hasTag string
+
+	// offsetOnly skips rows without limiting how many are returned
+	offsetOnly string
+}
+
+// sqlStore is the Store of every SQL database.
+type sqlStore struct {
+	db *DB
+	d  dialect
+}
+
+func newSQLStore(conn *sql.DB, d dialect) *sqlStore {
+	return &sqlStore{db: &DB{conn: conn, rebind: d.rebind}, d: d}
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
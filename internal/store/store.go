@@ -0,0 +1,434 @@
+// Package store is where queuectl keeps its jobs. Store is the one
+// interface everything that reads or changes the queue goes through:
+// enqueueing, claiming and finishing jobs, listing them and their history,
+// workers and their control messages, queue pauses, recurring schedules
+// and configuration.
+// There are two implementations sharing the same SQL, SQLite for a single
+// machine and PostgreSQL for workers spread over several, which differ
+// only where the databases do (see dialect).
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"queuectl/internal/db"
+)
+
+// ErrNoJob is returned by Claim when no job is ready to run.
+var ErrNoJob = errors.New("no job ready")
+
+// Store is the queue's storage. Every method is safe to call from many
+// goroutines and many processes at once.
+type Store interface {
+	// Enqueue adds jobs in a single transaction: all of them or none.
+	Enqueue(jobs []NewJob) error
+
+	// Claim moves the most urgent ready job to processing for a worker.
+	// It returns ErrNoJob when there is none; two workers never get the
+	// same job.
+	Claim(r ClaimRequest) (*ClaimedJob, error)
+
+	// Complete, Fail, Requeue and Cancelled end an attempt of a claimed
+	// job. Each one only applies while the job still carries the claim
+	// token it was claimed with, and reports whether it did.
+	Complete(job *ClaimedJob, e Event) (bool, error)
+	Fail(job *ClaimedJob, f Failure) (bool, error)
+	Requeue(job *ClaimedJob, r Requeue) (bool, error)
+	Cancelled(job *ClaimedJob, e Event) (bool, error)
+
+	// Cancel moves a job that is waiting to run, pending or failed, to
+	// cancelled, provided it is still in fromState.
+	Cancel(jobId, fromState string, c Cancellation) (bool, error)
+
+	// RequestCancel asks the worker running a processing job to stop it;
+	// the worker then moves it to cancelled. It reports false for a job
+	// that isn't processing or was already asked.
+	RequestCancel(jobId string, c Cancellation) (bool, error)
+
+	// RetryDead moves a dead job back to pending with its attempts reset
+	// and, if maxRetries is valid, a new retry limit.
+	RetryDead(jobId string, maxRetries sql.NullInt64, actor string) (bool, error)
+
+	// CancelRequested reports whether "queuectl cancel" asked for the job
+	// workerId is running to be stopped.
+	CancelRequested(jobId, workerId string) (bool, error)
+
+	// RecordEvent appends e to the history of a job.
+	RecordEvent(jobId string, e Event) error
+
+	// GetJob returns sql.ErrNoRows for a job that doesn't exist.
+	GetJob(id string) (Job, error)
+
+	// ListJobs returns one page of the jobs matching f and the cursor of
+	// the next page, or "" when this was the last one.
+	ListJobs(f ListFilter) ([]Job, string, error)
+	CountJobs(f ListFilter) (int, error)
+
+	StartAttempt(jobId, workerId string) (int64, int, error)
+	SaveAttemptOutput(attemptId int64, stdout, stderr string) error
+	FinishAttempt(attemptId int64, r AttemptResult) error
+
+	RegisterWorker(w Worker) error
+	UnregisterWorker(workerId string) error
+	SetWorkerState(workerId, state string) error
+
+	// Heartbeat marks the worker alive and extends the lease of the job
+	// it is running by lease.
+	Heartbeat(workerId string, lease time.Duration) error
+
+	// ReapExpiredLeases recovers the jobs of workers that stopped
+	// heartbeating and returns how many jobs and workers it cleaned up.
+	ReapExpiredLeases(lease time.Duration) (int64, int64, error)
+
+	// WorkerCommands returns the control messages workerId hasn't
+	// acknowledged yet, oldest first.
+	WorkerCommands(workerId string) ([]WorkerCommand, error)
+	AckWorkerCommand(id int64) error
+
+	// ConfigValue returns the stored value of key for queue, or the global
+	// one when queue is "" or has none, and where it came from: "queue" or
+	// "global". Both are "" when the key was never set.
+	ConfigValue(queue, key string) (string, string, error)
+
+	// SetConfig and UnsetConfig change the global value when queue is "".
+	SetConfig(queue, key, value string) error
+	UnsetConfig(queue, key string) error
+
+	// QueueConfig lists every per-queue value, by queue and key.
+	QueueConfig() ([]QueueSetting, error)
+
+	// JobAttempts returns the runs of a job, oldest first, or only run
+	// number attempt when it isn't 0.
+	JobAttempts(jobId string, attempt int) ([]Attempt, error)
+
+	// JobEvents returns the history of a job, oldest first.
+	JobEvents(jobId string) ([]Event, error)
+
+	// QueueCounts counts the jobs of every queue by state, ordered by
+	// queue and state.
+	QueueCounts() ([]QueueCount, error)
+
+	// PauseQueue stops workers from claiming jobs of p.Queue, replacing
+	// any earlier pause of it. PausedAt defaults to now.
+	PauseQueue(p QueuePause) error
+
+	// ResumeQueue lifts the pause of queue and reports whether there was
+	// one; a pause whose ResumeAt has passed doesn't count.
+	ResumeQueue(queue string) (bool, error)
+
+	// PausedQueues returns the pauses in force now, by queue.
+	PausedQueues() (map[string]QueuePause, error)
+
+	// Workers returns the registered workers, latest heartbeat first.
+	Workers() ([]WorkerStatus, error)
+
+	// SendWorkerCommand queues command for every one of workerIds in a
+	// single transaction.
+	SendWorkerCommand(workerIds []string, command string) error
+
+	// SentWorkerCommands returns the control messages still waiting for
+	// their worker and those acknowledged at or after since, newest first
+	// and at most limit of them.
+	SentWorkerCommands(since string, limit int) ([]SentWorkerCommand, error)
+
+	// AddSchedule stores a new recurring job. CreatedAt defaults to now.
+	AddSchedule(s Schedule) error
+
+	// Schedules returns every schedule, oldest first.
+	Schedules() ([]Schedule, error)
+
+	// GetSchedule returns sql.ErrNoRows for a schedule that doesn't exist.
+	GetSchedule(id string) (Schedule, error)
+
+	// PauseSchedule and ResumeSchedule report false for a schedule that
+	// doesn't exist. A resumed schedule next runs at nextRunAt.
+	PauseSchedule(id string) (bool, error)
+	ResumeSchedule(id, nextRunAt string) (bool, error)
+
+	// DeleteSchedule keeps the jobs the schedule already enqueued.
+	DeleteSchedule(id string) (bool, error)
+
+	// DueSchedules returns the active schedules whose next run is at or
+	// before at.
+	DueSchedules(at string) ([]Schedule, error)
+
+	// AdvanceSchedule moves s on to nextRunAt and enqueues jobs for the
+	// occurrences it passed, in one transaction. It only applies while the
+	// schedule is active and still at s.NextRunAt, and reports whether it
+	// did, so that of several processes advancing it only one enqueues.
+	AdvanceSchedule(s Schedule, nextRunAt string, lastRunAt sql.NullString, jobs []NewJob) (bool, error)
+
+	Close() error
+}
+
+// New returns the Store for a database opened with db.Connect.
+func New(conn *sql.DB, schema *db.Schema) Store {
+	if schema == db.Postgres {
+		return NewPostgres(conn)
+	}
+	return NewSQLite(conn)
+}
+
+// Open connects to the database dsn names and returns its Store. The
+// schema must already be at the version this binary expects.
+func Open(dsn string, opts db.Options) (Store, error) {
+	conn, schema, err := db.Connect(dsn, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := schema.Check(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return New(conn, schema), nil
+}
+
+// NewJob is a validated job ready to be stored. Nullable columns fall back
+// to configuration when NULL.
+type NewJob struct {
+	Id         string
+	Queue      string
+	Command    string
+	State      string
+	Attempts   int
+	MaxRetries int
+	Priority   int
+	CreatedAt  string
+	UpdatedAt  string
+	NextRunAt  sql.NullString
+
+	TimeoutSeconds sql.NullInt64
+	Env            sql.NullString
+	Tags           sql.NullString
+
+	BackoffStrategy    sql.NullString
+	BackoffBaseSeconds sql.NullInt64
+	BackoffMaxSeconds  sql.NullInt64
+
+	RetryOn    sql.NullString
+	FailFastOn sql.NullString
+
+	// Set for jobs enqueued by a recurring schedule
+	ScheduleId   sql.NullString
+	ScheduledFor sql.NullString
+
+	// The handler that runs the job and its JSON input, for jobs meant
+	// for a worker embedded in a Go program
+	Type    sql.NullString
+	Payload sql.NullString
+
+	// Actor is who enqueued the job, for its history
+	Actor string
+}
+
+// Job is a stored job as the read commands see it.
+type Job struct {
+	Id         string
+	Queue      string
+	Command    string
+	State      string
+	Attempts   int
+	MaxRetries int
+	Priority   int
+	CreatedAt  string
+	UpdatedAt  string
+
+	TimeoutSeconds     sql.NullInt64
+	BackoffStrategy    sql.NullString
+	BackoffBaseSeconds sql.NullInt64
+	BackoffMaxSeconds  sql.NullInt64
+	RetryOn            sql.NullString
+	FailFastOn         sql.NullString
+	Env                sql.NullString
+	Tags               sql.NullString
+	Type               sql.NullString
+	Payload            sql.NullString
+
+	NextRunAt         sql.NullString
+	WorkerId          sql.NullString
+	FailureReason     sql.NullString
+	CancelRequestedAt sql.NullString
+	CancelledAt       sql.NullString
+	CancelledBy       sql.NullString
+	CancelReason      sql.NullString
+
+	// Seq orders jobs by insertion; list cursors use it to break ties
+	Seq int64
+}
+
+// ClaimRequest asks for a job of Queue, or of any queue when it is "".
+// With a positive PriorityAging, every PriorityAging seconds a job has
+// been ready adds one to its effective priority. Jobs without a command
+// are only claimed when their type is one of Types, the handlers the
+// worker has.
+type ClaimRequest struct {
+	WorkerId      string
+	Queue         string
+	Lease         time.Duration
+	PriorityAging int
+	Types         []string
+}
+
+// ClaimedJob is what a worker needs to run a job it claimed. Every later
+// update of the job must match ClaimToken.
+type ClaimedJob struct {
+	Id         string
+	Queue      string
+	Command    string
+	Attempts   int
+	MaxRetries int
+	Priority   int
+	ClaimToken string
+
+	TimeoutSeconds sql.NullInt64
+	Env            sql.NullString
+
+	BackoffStrategy    sql.NullString
+	BackoffBaseSeconds sql.NullInt64
+	BackoffMaxSeconds  sql.NullInt64
+	LastBackoffSeconds sql.NullInt64
+
+	RetryOn    sql.NullString
+	FailFastOn sql.NullString
+
+	Type    sql.NullString
+	Payload sql.NullString
+}
+
+// Failure is how a failed attempt ends: the job is dead, or failed and
+// pending again from NextRunAt.
+type Failure struct {
+	// Attempts used so far, this one included
+	Attempts       int
+	Reason         string
+	Dead           bool
+	NextRunAt      string
+	BackoffSeconds int64
+
+	// Event is the "failed" or "dead" entry of the job's history, Retry
+	// the "retry_scheduled" one that follows a failure
+	Event Event
+	Retry Event
+}
+
+// Requeue hands a claimed job back to pending without using up an
+// attempt, e.g. because its worker shut down. A valid BackoffSeconds
+// replaces the job's last backoff delay.
+type Requeue struct {
+	Reason         string
+	NextRunAt      string
+	BackoffSeconds sql.NullInt64
+	Event          Event
+}
+
+// Cancellation is who cancelled a job and why. Reason may be empty.
+type Cancellation struct {
+	By     string
+	Reason string
+}
+
+// AttemptResult is how one run of a job ended and what it printed.
+type AttemptResult struct {
+	ExitCode int
+	Signal   string
+	Stdout   string
+	Stderr   string
+}
+
+// Worker is a worker process as it registers itself.
+type Worker struct {
+	Id     string
+	Queues string
+	Host   string
+	Pid    int
+}
+
+// WorkerCommand is a control message sent to a worker.
+type WorkerCommand struct {
+	Id      int64
+	Command string
+}
+
+// Attempt is one run of a job as job_attempts recorded it. FinishedAt is
+// NULL while it runs; ExitCode is NULL for a run whose worker was lost.
+type Attempt struct {
+	Id         int64
+	Attempt    int
+	WorkerId   sql.NullString
+	StartedAt  sql.NullString
+	FinishedAt sql.NullString
+	ExitCode   sql.NullInt64
+	Signal     sql.NullString
+	Stdout     sql.NullString
+	Stderr     sql.NullString
+}
+
+// QueueCount is how many jobs of Queue are in State.
+type QueueCount struct {
+	Queue string
+	State string
+	Count int
+}
+
+// QueuePause is a paused queue: workers claim nothing from it until it is
+// resumed or, when ResumeAt is set, until then. PausedBy, Reason and
+// ResumeAt may be empty.
+type QueuePause struct {
+	Queue    string
+	PausedAt string
+	PausedBy string
+	Reason   string
+	ResumeAt string
+}
+
+// WorkerStatus is a registered worker as "queuectl status" shows it.
+type WorkerStatus struct {
+	Worker
+	State         string
+	StartedAt     string
+	LastHeartbeat string
+}
+
+// SentWorkerCommand is a control message and whether its worker has
+// acknowledged it. AckedAt is empty until it does.
+type SentWorkerCommand struct {
+	WorkerCommand
+	WorkerId  string
+	CreatedAt string
+	AckedAt   string
+}
+
+// Schedule is a recurring job. MaxRetries and TimeoutSeconds apply to
+// every job it enqueues and fall back to configuration when NULL.
+type Schedule struct {
+	Id             string
+	Queue          string
+	Cron           string
+	Command        string
+	MaxRetries     sql.NullInt64
+	TimeoutSeconds sql.NullInt64
+	CatchUp        string
+	Paused         bool
+	NextRunAt      string
+	LastRunAt      sql.NullString
+	CreatedAt      string
+}
+
+// QueueSetting is a configuration value that only applies to one queue.
+type QueueSetting struct {
+	Queue string
+	Key   string
+	Value string
+}
+
+// now is the time in the format every timestamp is stored in: UTC
+// RFC3339, so that both databases can compare them as plain strings.
+func now() string {
+	return formatTime(time.Now())
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,334 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// StartAttempt records the beginning of a new run of a job and returns the
+// row id together with the attempt number (1 for the first run ever,
+// counting runs from before a DLQ retry too).
+func (s *sqlStore) StartAttempt(jobId, workerId string) (int64, int, error) {
+	var rowId int64
+	var attempt int
+
+	err := s.db.QueryRow(`
+		INSERT INTO job_attempts (JobId, Attempt, WorkerId, Started_at)
+		SELECT ?, COALESCE(MAX(Attempt), 0) + 1, ?, ?
+		FROM job_attempts WHERE JobId = ?
+		RETURNING Id, Attempt
+	`, jobId, workerId, now(), jobId).Scan(&rowId, &attempt)
+
+	return rowId, attempt, err
+}
+
+// SaveAttemptOutput stores the output captured so far, letting
+// `queuectl logs --follow` show a command's progress before it exits.
+func (s *sqlStore) SaveAttemptOutput(attemptId int64, stdout, stderr string) error {
+	_, err := s.db.Exec(`
+		UPDATE job_attempts SET Stdout=?, Stderr=? WHERE Id=?
+	`, stdout, stderr, attemptId)
+	return err
+}
+
+func (s *sqlStore) FinishAttempt(attemptId int64, r AttemptResult) error {
+	var signal sql.NullString
+	if r.Signal != "" {
+		signal = sql.NullString{String: r.Signal, Valid: true}
+	}
+
+	_, err := s.db.Exec(`
+		UPDATE job_attempts
+		SET Finished_at=?, Exit_code=?, Signal=?, Stdout=?, Stderr=?
+		WHERE Id=?
+	`, now(), r.ExitCode, signal, r.Stdout, r.Stderr, attemptId)
+	return err
+}
+
+func (s *sqlStore) RegisterWorker(w Worker) error {
+	nowStr := now()
+	_, err := s.db.Exec(`
+		INSERT INTO workers (WorkerId, Started_at, Last_heartbeat, Queues, Host, Pid, State)
+		VALUES (?, ?, ?, ?, ?, ?, 'running')
+	`, w.Id, nowStr, nowStr, nullIfEmpty(w.Queues), w.Host, w.Pid)
+	return err
+}
+
+func (s *sqlStore) UnregisterWorker(workerId string) error {
+	_, err := s.db.Exec(`DELETE FROM workers WHERE WorkerId=?`, workerId)
+	return err
+}
+
+// SetWorkerState records what the worker is doing for "queuectl status".
+func (s *sqlStore) SetWorkerState(workerId, state string) error {
+	_, err := s.db.Exec(`UPDATE workers SET State=? WHERE WorkerId=?`, state, workerId)
+	return err
+}
+
+func (s *sqlStore) Heartbeat(workerId string, lease time.Duration) error {
+	t := time.Now()
+
+	_, err := s.db.Exec(`
+		UPDATE workers SET Last_heartbeat=? WHERE WorkerId=?
+	`, formatTime(t), workerId)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+		UPDATE jobs SET Lease_expires_at=?
+		WHERE WorkerId=? AND State='processing'
+	`, formatTime(t.Add(lease)), workerId)
+	return err
+}
+
+// ReapExpiredLeases recovers jobs whose worker stopped renewing the lease,
+// e.g. because the process was killed mid-job. Each recovered job counts
+// as a failed attempt: it goes back to pending, or to dead once it has
+// used up its Max_retries. Worker rows that missed a whole lease worth of
+// heartbeats are deleted. Any live worker may run this.
+func (s *sqlStore) ReapExpiredLeases(lease time.Duration) (int64, int64, error) {
+	t := time.Now()
+	nowStr := formatTime(t)
+	staleBefore := formatTime(t.Add(-lease))
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	// Jobs claimed before leases existed have no expiry; fall back to
+	// their last update so they are not stuck forever. Jobs cancelled while
+	// their worker was dying are not run again.
+	expired := `
+		State = 'processing'
+		AND (
+			Lease_expires_at < ?
+			OR (Lease_expires_at IS NULL AND Updated_at < ?)
+		)`
+	nextState := `CASE
+				WHEN Cancel_requested_at IS NOT NULL THEN 'cancelled'
+				WHEN Attempts + 1 >= Max_retries THEN 'dead'
+				ELSE 'pending'
+			END`
+
+	// Where several reapers can run at once, lock the expired jobs first
+	// so that only one of them records their recovery. They lock in Id
+	// order, or two reapers could each hold a row the other waits for, and
+	// only the locked jobs are reaped: one that expired since may already
+	// be locked by another reaper.
+	reap, reapArgs := expired, []any{nowStr, staleBefore}
+	if s.d.forUpdate != "" {
+		rows, err := tx.Query(`SELECT Id FROM jobs WHERE `+expired+` ORDER BY Id `+s.d.forUpdate, nowStr, staleBefore)
+		if err != nil {
+			return 0, 0, err
+		}
+		reapArgs = nil
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return 0, 0, err
+			}
+			reapArgs = append(reapArgs, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, 0, err
+		}
+		if len(reapArgs) > 0 {
+			reap = "Id IN (?" + strings.Repeat(", ?", len(reapArgs)-1) + ")"
+		}
+	}
+
+	var jobsReaped int64
+	if len(reapArgs) > 0 {
+		// Record the recovery in each job's history before the worker is
+		// cleared from the row
+		_, err = tx.Exec(`
+			INSERT INTO job_events (JobId, At, Event, From_state, To_state, Attempt, WorkerId, Detail)
+			SELECT Id, ?, 'lease_expired', 'processing', `+nextState+`,
+				(SELECT MAX(Attempt) FROM job_attempts WHERE JobId = jobs.Id),
+				WorkerId, 'worker stopped renewing its lease'
+			FROM jobs
+			WHERE `+reap, append([]any{nowStr}, reapArgs...)...)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		res, err := tx.Exec(`
+			UPDATE jobs
+			SET State = `+nextState+`,
+				Cancelled_at = CASE WHEN Cancel_requested_at IS NOT NULL THEN ? END,
+				Attempts = Attempts + 1,
+				Failure_reason = 'lease_expired',
+				WorkerId = NULL,
+				Claim_token = NULL,
+				Lease_expires_at = NULL,
+				Next_run_at = ?,
+				Updated_at = ?
+			WHERE `+reap, append([]any{nowStr, nowStr, nowStr}, reapArgs...)...)
+		if err != nil {
+			return 0, 0, err
+		}
+		jobsReaped, _ = res.RowsAffected()
+	}
+
+	res, err := tx.Exec(`DELETE FROM workers WHERE Last_heartbeat < ?`, staleBefore)
+	if err != nil {
+		return 0, 0, err
+	}
+	workersReaped, _ := res.RowsAffected()
+
+	// Close out attempts whose worker is gone so they don't look like
+	// they're still running
+	_, err = tx.Exec(`
+		UPDATE job_attempts SET Finished_at = ?
+		WHERE Finished_at IS NULL
+		AND WorkerId NOT IN (SELECT WorkerId FROM workers)
+	`, nowStr)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Nobody is left to act on control messages sent to those workers
+	_, err = tx.Exec(`
+		DELETE FROM worker_commands
+		WHERE Acked_at IS NULL
+		AND WorkerId NOT IN (SELECT WorkerId FROM workers)
+	`)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return jobsReaped, workersReaped, tx.Commit()
+}
+
+func (s *sqlStore) WorkerCommands(workerId string) ([]WorkerCommand, error) {
+	rows, err := s.db.Query(`
+		SELECT Id, Command FROM worker_commands
+		WHERE WorkerId=? AND Acked_at IS NULL
+		ORDER BY Id
+	`, workerId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var commands []WorkerCommand
+	for rows.Next() {
+		var c WorkerCommand
+		if err := rows.Scan(&c.Id, &c.Command); err != nil {
+			return nil, err
+		}
+		commands = append(commands, c)
+	}
+	return commands, rows.Err()
+}
+
+func (s *sqlStore) AckWorkerCommand(id int64) error {
+	_, err := s.db.Exec(`UPDATE worker_commands SET Acked_at=? WHERE Id=?`, now(), id)
+	return err
+}
+
+func (s *sqlStore) JobAttempts(jobId string, attempt int) ([]Attempt, error) {
+	rows, err := s.db.Query(`
+		SELECT Id, Attempt, WorkerId, Started_at, Finished_at, Exit_code, Signal, Stdout, Stderr
+		FROM job_attempts
+		WHERE JobId = ? AND (? = 0 OR Attempt = ?)
+		ORDER BY Attempt
+	`, jobId, attempt, attempt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []Attempt
+	for rows.Next() {
+		var a Attempt
+		err := rows.Scan(&a.Id, &a.Attempt, &a.WorkerId, &a.StartedAt, &a.FinishedAt,
+			&a.ExitCode, &a.Signal, &a.Stdout, &a.Stderr)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
+
+func (s *sqlStore) Workers() ([]WorkerStatus, error) {
+	rows, err := s.db.Query(`
+		SELECT WorkerId, Host, Pid, State, Started_at, Last_heartbeat, Queues
+		FROM workers
+		ORDER BY Last_heartbeat DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var workers []WorkerStatus
+	for rows.Next() {
+		var w WorkerStatus
+		var host, startedAt, lastHeartbeat, queues sql.NullString
+		var pid sql.NullInt64
+		if err := rows.Scan(&w.Id, &host, &pid, &w.State, &startedAt, &lastHeartbeat, &queues); err != nil {
+			return nil, err
+		}
+		w.Host = host.String
+		w.Pid = int(pid.Int64)
+		w.StartedAt = startedAt.String
+		w.LastHeartbeat = lastHeartbeat.String
+		w.Queues = queues.String
+		workers = append(workers, w)
+	}
+	return workers, rows.Err()
+}
+
+func (s *sqlStore) SendWorkerCommand(workerIds []string, command string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	nowStr := now()
+	for _, id := range workerIds {
+		_, err := tx.Exec(`
+			INSERT INTO worker_commands (WorkerId, Command, Created_at)
+			VALUES (?, ?, ?)
+		`, id, command, nowStr)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) SentWorkerCommands(since string, limit int) ([]SentWorkerCommand, error) {
+	rows, err := s.db.Query(`
+		SELECT Id, WorkerId, Command, Created_at, Acked_at
+		FROM worker_commands
+		WHERE Acked_at IS NULL OR Acked_at >= ?
+		ORDER BY Id DESC
+		LIMIT ?
+	`, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var commands []SentWorkerCommand
+	for rows.Next() {
+		var c SentWorkerCommand
+		var ackedAt sql.NullString
+		if err := rows.Scan(&c.Id, &c.WorkerId, &c.Command, &c.CreatedAt, &ackedAt); err != nil {
+			return nil, err
+		}
+		c.AckedAt = ackedAt.String
+		commands = append(commands, c)
+	}
+	return commands, rows.Err()
+}
@@ -0,0 +1,121 @@
+// Package client lets Go programs use a queuectl queue directly instead of
+// running the queuectl binary: enqueue jobs, look them up, cancel them,
+// retry dead ones and change the configuration. The queuectl commands are
+// built on it too.
+//
+//	c, err := client.Open("data/queue.db", client.Options{})
+//	if err != nil {
+//		return err
+//	}
+//	defer c.Close()
+//
+//	job, err := c.Enqueue(ctx, client.JobSpec{Command: "./send-report.sh", Queue: "reports"})
+//
+// Errors are returned, never printed. Lookups of a job that doesn't exist
+// fail with ErrNotFound, and operations that don't apply to the job's
+// current state with a *StateError.
+//
+// Every method checks ctx before it touches the database; a query that
+// has already started runs to completion.
+package client
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	osuser "os/user"
+	"sync"
+	"time"
+
+	"queuectl/internal/db"
+	"queuectl/internal/store"
+)
+
+// ErrNotFound is returned for a job id that doesn't exist.
+var ErrNotFound = errors.New("no such job")
+
+// ErrInvalidCursor is returned by List for an After cursor that it didn't
+// make, or made for another sort.
+var ErrInvalidCursor = store.ErrInvalidCursor
+
+// StateError is returned when a job isn't in a state the operation applies
+// to, e.g. when retrying a job that isn't dead.
+type StateError struct {
+	Id    string
+	State string
+
+	// Want describes the states the operation needed
+	Want string
+}
+
+func (e *StateError) Error() string {
+	return fmt.Sprintf("job %s is %s, not %s", e.Id, e.State, e.Want)
+}
+
+// Options tune Open.
+type Options struct {
+	// BusyTimeout is how long an SQLite statement waits for another
+	// process's lock; 0 uses the default.
+	BusyTimeout time.Duration
+
+	// Actor is recorded in job histories as who enqueued, cancelled or
+	// retried a job; it defaults to CurrentUser.
+	Actor string
+}
+
+// Client is a connection to one queue. It is safe for concurrent use.
+type Client struct {
+	st    store.Store
+	actor string
+}
+
+// Open connects to the database dsn names: the path of an SQLite file or
+// a postgres:// URL. The database must already exist and be at the schema
+// version this package expects; see "queuectl db init" and "queuectl db
+// migrate".
+func Open(dsn string, opts Options) (*Client, error) {
+	st, err := store.Open(dsn, db.Options{BusyTimeout: opts.BusyTimeout})
+	if err != nil {
+		return nil, err
+	}
+	return New(st, opts), nil
+}
+
+// New returns a Client for a store that is already open, so that the
+// queuectl commands and pkg/worker can share theirs with it. Only queuectl's
+// own packages can open a store; other programs use Open. BusyTimeout is
+// ignored, and closing the Client closes st.
+func New(st store.Store, opts Options) *Client {
+	actor := opts.Actor
+	if actor == "" {
+		actor = CurrentUser()
+	}
+	return &Client{st: st, actor: actor}
+}
+
+// Close closes the database.
+func (c *Client) Close() error {
+	return c.st.Close()
+}
+
+// CurrentUser names whoever runs the program as user@host, for the audit
+// fields of the jobs it touches.
+var CurrentUser = sync.OnceValue(func() string {
+	user := os.Getenv("USER")
+	if user == "" {
+		user = os.Getenv("USERNAME")
+	}
+	if user == "" {
+		if u, err := osuser.Current(); err == nil {
+			user = u.Username
+		}
+	}
+	if host, err := os.Hostname(); err == nil {
+		user += "@" + host
+	}
+	return user
+})
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,248 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ErrUnknownConfigKey is returned for a key that isn't one of ConfigKeys.
+var ErrUnknownConfigKey = errors.New("unknown configuration key")
+
+// configDefaults are the built-in values of every key, used when it was
+// never set.
+var configDefaults = map[string]string{
+	"max-retries":          "3",
+	"backoff-strategy":     "exponential",
+	"backoff-base":         "2",
+	"backoff-max":          "3600",
+	"retry-on":             "",
+	"fail-fast-on":         "",
+	"reschedule-exit-code": "0",
+	"lease-timeout":        "30",
+	"job-timeout":          "0",
+	"output-limit":         "65536",
+	"priority-aging":       "0",
+}
+
+// configKeys is the order Config lists keys in.
+var configKeys = []string{
+	"max-retries", "backoff-strategy", "backoff-base", "backoff-max",
+	"retry-on", "fail-fast-on", "reschedule-exit-code",
+	"lease-timeout", "job-timeout", "output-limit", "priority-aging",
+}
+
+// queueConfigKeys are the keys that can be overridden per queue. The rest
+// tune a whole worker process rather than the jobs it runs.
+var queueConfigKeys = map[string]bool{
+	"max-retries":          true,
+	"backoff-strategy":     true,
+	"backoff-base":         true,
+	"backoff-max":          true,
+	"retry-on":             true,
+	"fail-fast-on":         true,
+	"reschedule-exit-code": true,
+	"job-timeout":          true,
+	"output-limit":         true,
+}
+
+// ConfigKeys lists every configuration key.
+func ConfigKeys() []string {
+	return append([]string(nil), configKeys...)
+}
+
+// IsQueueConfigKey reports whether key can be set for a single queue.
+func IsQueueConfigKey(key string) bool {
+	return queueConfigKeys[key]
+}
+
+// DescribeConfig explains what a key means.
+func DescribeConfig(key string) string {
+	switch key {
+	case "max-retries":
+		return "Maximum retry attempts before moving to DLQ"
+	case "backoff-strategy":
+		return "How retry delays grow"
+	case "backoff-base":
+		return "First retry delay in seconds"
+	case "backoff-max":
+		return "Longest retry delay in seconds"
+	case "retry-on":
+		return "Only these exit codes are retried (empty = any)"
+	case "fail-fast-on":
+		return "These exit codes go straight to the DLQ"
+	case "reschedule-exit-code":
+		return "Exit code that reschedules without using an attempt (0 = off)"
+	case "lease-timeout":
+		return "Seconds without a heartbeat before a job is recovered"
+	case "job-timeout":
+		return "Seconds before a job without --timeout is killed (0 = never)"
+	case "output-limit":
+		return "Bytes of stdout/stderr kept per attempt"
+	case "priority-aging":
+		return "Seconds of waiting per +1 priority (0 = strict)"
+	}
+	return ""
+}
+
+// ConfigValue is the value of a key and where it came from: "queue",
+// "global" or "default". Queue is set for values that only apply to one
+// queue.
+type ConfigValue struct {
+	Queue       string `json:"queue,omitempty" yaml:"queue,omitempty"`
+	Key         string `json:"key" yaml:"key"`
+	Value       string `json:"value" yaml:"value"`
+	Source      string `json:"source" yaml:"source"`
+	Description string `json:"description" yaml:"description"`
+}
+
+// Int is the value of a numeric key, or its default if the stored value
+// isn't a number.
+func (v ConfigValue) Int() int {
+	n, err := strconv.Atoi(v.Value)
+	if err != nil {
+		n, _ = strconv.Atoi(configDefaults[v.Key])
+	}
+	return n
+}
+
+// GetConfig returns the value of key for jobs in queue. An empty queue
+// skips the queue overrides, as does a key that can't be set per queue.
+// Settings stored on the job itself (Max_retries, Timeout, Backoff...)
+// win over all of these. If the database can't be read the default is
+// returned along with the error.
+func (c *Client) GetConfig(ctx context.Context, queue, key string) (ConfigValue, error) {
+	def, ok := configDefaults[key]
+	if !ok {
+		return ConfigValue{}, fmt.Errorf("%w: %s", ErrUnknownConfigKey, key)
+	}
+	result := ConfigValue{Key: key, Value: def, Source: "default", Description: DescribeConfig(key)}
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	if !queueConfigKeys[key] {
+		queue = ""
+	}
+	value, source, err := c.st.ConfigValue(queue, key)
+	if err != nil || source == "" {
+		return result, err
+	}
+
+	result.Value, result.Source = value, source
+	if source == "queue" {
+		result.Queue = queue
+	}
+	return result, nil
+}
+
+// Config returns the value of every key for jobs in queue, in the order of
+// ConfigKeys.
+func (c *Client) Config(ctx context.Context, queue string) ([]ConfigValue, error) {
+	var values []ConfigValue
+	for _, key := range configKeys {
+		v, err := c.GetConfig(ctx, queue, key)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// QueueOverrides returns every value set for a single queue, by queue and
+// key.
+func (c *Client) QueueOverrides(ctx context.Context) ([]ConfigValue, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	settings, err := c.st.QueueConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var values []ConfigValue
+	for _, q := range settings {
+		values = append(values, ConfigValue{
+			Queue: q.Queue, Key: q.Key, Value: q.Value, Source: "queue", Description: DescribeConfig(q.Key),
+		})
+	}
+	return values, nil
+}
+
+// ValidateConfig checks value for key, as SetConfig does, and returns it
+// the way it would be stored.
+func ValidateConfig(queue, key, value string) (string, error) {
+	if _, ok := configDefaults[key]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownConfigKey, key)
+	}
+	if queue != "" {
+		if err := ValidateQueueName(queue); err != nil {
+			return "", err
+		}
+		if !queueConfigKeys[key] {
+			return "", fmt.Errorf("%s applies to whole workers and can't be set per queue", key)
+		}
+	}
+
+	switch key {
+	case "backoff-strategy":
+		return value, ValidateBackoffStrategy(value)
+	case "retry-on", "fail-fast-on":
+		codes, err := ParseExitCodes(value)
+		if err != nil {
+			return "", err
+		}
+		return FormatExitCodes(codes), nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return "", fmt.Errorf("value must be a number, got: %s", value)
+	}
+
+	switch key {
+	case "max-retries", "job-timeout", "priority-aging":
+		if n < 0 {
+			return "", fmt.Errorf("%s must be >= 0", key)
+		}
+	case "backoff-base", "backoff-max", "lease-timeout", "output-limit":
+		if n < 1 {
+			return "", fmt.Errorf("%s must be >= 1", key)
+		}
+	case "reschedule-exit-code":
+		if n < 0 || n > 255 {
+			return "", fmt.Errorf("%s must be between 0 and 255", key)
+		}
+	}
+	return value, nil
+}
+
+// SetConfig sets key for jobs in queue, or globally when queue is "". It
+// returns the value as stored, e.g. exit code lists are normalized.
+func (c *Client) SetConfig(ctx context.Context, queue, key, value string) (string, error) {
+	value, err := ValidateConfig(queue, key, value)
+	if err != nil {
+		return "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return value, c.st.SetConfig(queue, key, value)
+}
+
+// UnsetConfig removes the value of key set for queue, or the global one
+// when queue is "", and returns the value that applies now.
+func (c *Client) UnsetConfig(ctx context.Context, queue, key string) (ConfigValue, error) {
+	if _, ok := configDefaults[key]; !ok {
+		return ConfigValue{}, fmt.Errorf("%w: %s", ErrUnknownConfigKey, key)
+	}
+	if err := ctx.Err(); err != nil {
+		return ConfigValue{}, err
+	}
+	if err := c.st.UnsetConfig(queue, key); err != nil {
+		return ConfigValue{}, err
+	}
+	return c.GetConfig(ctx, queue, key)
+}
@@ -0,0 +1,284 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"queuectl/internal/store"
+)
+
+// Enqueue adds one job and returns it as stored. Jobs without Max_retries
+// get the value configured for their queue.
+func (c *Client) Enqueue(ctx context.Context, spec JobSpec) (Job, error) {
+	jobs, err := c.EnqueueBatch(ctx, []JobSpec{spec})
+	if err != nil {
+		var invalid *InvalidJobsError
+		if errors.As(err, &invalid) {
+			return Job{}, invalid.Jobs[0].Err
+		}
+		return Job{}, err
+	}
+	return jobs[0], nil
+}
+
+// EnqueueBatch adds jobs in a single transaction: if any spec is invalid,
+// it returns an *InvalidJobsError listing all of them and nothing is
+// added.
+func (c *Client) EnqueueBatch(ctx context.Context, specs []JobSpec) ([]Job, error) {
+	prepared, err := prepareJobs(specs)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	rows := make([]store.NewJob, len(prepared))
+	jobs := make([]Job, len(prepared))
+	for i, job := range prepared {
+		if job.Spec.Max_retries == nil {
+			cfg, err := c.GetConfig(ctx, job.Spec.Queue, "max-retries")
+			if err != nil {
+				return nil, err
+			}
+			maxRetries := cfg.Int()
+			job.Spec.Max_retries = &maxRetries
+		}
+		rows[i] = job.storeJob(c.actor)
+		jobs[i] = Job{JobSpec: job.Spec, Next_run_at: job.NextRunAt.String}
+	}
+
+	if err := c.st.Enqueue(rows); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// Get returns the job with id, or ErrNotFound.
+func (c *Client) Get(ctx context.Context, id string) (Job, error) {
+	if err := ctx.Err(); err != nil {
+		return Job{}, err
+	}
+	j, err := c.st.GetJob(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Job{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return Job{}, err
+	}
+	return newJob(j), nil
+}
+
+// ListOptions selects jobs for List and Count. Conditions combine with
+// AND; zero values don't filter.
+type ListOptions struct {
+	// State "scheduled" means pending jobs that aren't due yet
+	State string
+	Queue string
+	Type  string
+
+	// WorkerId ran the job's latest attempt, whether it has ended or not
+	WorkerId string
+
+	// Command contains this text, ignoring case
+	Command string
+
+	// Command matches this regular expression
+	Match string
+
+	// Tags the job must all have
+	Tags []string
+
+	// After is inclusive, Before exclusive
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+
+	MinAttempts *int
+	MaxAttempts *int
+
+	// Sort is one of SortFields, "created_at" when empty
+	Sort string
+	Desc bool
+
+	// Limit of 0 returns every job. After is the cursor List returned
+	// for the previous page; it can't be combined with Offset.
+	Limit  int
+	Offset int
+	After  string
+}
+
+// SortFields are the fields List can sort by, alphabetically.
+func SortFields() []string {
+	return store.SortFields()
+}
+
+func (o ListOptions) filter() (store.ListFilter, error) {
+	if o.Match != "" {
+		if _, err := regexp.Compile(o.Match); err != nil {
+			return store.ListFilter{}, fmt.Errorf("invalid match: %w", err)
+		}
+	}
+	if o.Limit < 0 || o.Offset < 0 {
+		return store.ListFilter{}, errors.New("limit and offset must be >= 0")
+	}
+
+	timeString := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return formatTime(t)
+	}
+
+	return store.ListFilter{
+		State:         o.State,
+		Queue:         o.Queue,
+		WorkerId:      o.WorkerId,
+		Type:          o.Type,
+		Command:       o.Command,
+		Match:         o.Match,
+		Tags:          o.Tags,
+		CreatedAfter:  timeString(o.CreatedAfter),
+		CreatedBefore: timeString(o.CreatedBefore),
+		UpdatedAfter:  timeString(o.UpdatedAfter),
+		UpdatedBefore: timeString(o.UpdatedBefore),
+		MinAttempts:   o.MinAttempts,
+		MaxAttempts:   o.MaxAttempts,
+		Sort:          o.Sort,
+		Desc:          o.Desc,
+		Limit:         o.Limit,
+		Offset:        o.Offset,
+		After:         o.After,
+	}, nil
+}
+
+// List returns one page of the jobs matching o and the cursor of the next
+// page, or "" when this was the last one.
+func (c *Client) List(ctx context.Context, o ListOptions) ([]Job, string, error) {
+	f, err := o.filter()
+	if err != nil {
+		return nil, "", err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+
+	stored, next, err := c.st.ListJobs(f)
+	if err != nil {
+		return nil, "", err
+	}
+	jobs := make([]Job, len(stored))
+	for i, j := range stored {
+		jobs[i] = newJob(j)
+	}
+	return jobs, next, nil
+}
+
+// Count returns how many jobs match o. Paging options are ignored.
+func (c *Client) Count(ctx context.Context, o ListOptions) (int, error) {
+	f, err := o.filter()
+	if err != nil {
+		return 0, err
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.st.CountJobs(f)
+}
+
+// ErrCancelRequested is returned by Cancel for a running job whose worker
+// was already asked to stop it.
+var ErrCancelRequested = errors.New("cancel already requested")
+
+// CancelOptions say who cancels a job and why.
+type CancelOptions struct {
+	// By defaults to the client's Actor
+	By     string
+	Reason string
+}
+
+// Cancel stops a job from running. A pending or failed job moves to
+// cancelled right away. For a processing job the worker running it is
+// asked to kill the command, and the job moves to cancelled once it has;
+// the returned job then still shows processing, with Cancel_requested_at
+// set. Asking again fails with ErrCancelRequested, and cancelling a job
+// that has finished with a *StateError.
+func (c *Client) Cancel(ctx context.Context, id string, opts CancelOptions) (Job, error) {
+	job, err := c.Get(ctx, id)
+	if err != nil {
+		return Job{}, err
+	}
+
+	cancellation := store.Cancellation{By: opts.By, Reason: opts.Reason}
+	if cancellation.By == "" {
+		cancellation.By = c.actor
+	}
+
+	// The state may move on while this runs, so each update only applies
+	// to the state it is meant for; a waiting job may have just been
+	// claimed, in which case its worker is asked instead
+	var ok bool
+	if job.State == "pending" || job.State == "failed" {
+		if ok, err = c.st.Cancel(id, job.State, cancellation); err != nil {
+			return Job{}, err
+		}
+	}
+	if !ok {
+		if ok, err = c.st.RequestCancel(id, cancellation); err != nil {
+			return Job{}, err
+		}
+	}
+
+	if !ok {
+		if job, err = c.Get(ctx, id); err != nil {
+			return Job{}, err
+		}
+		if job.State == "processing" && job.Cancel_requested_at != "" {
+			return job, fmt.Errorf("job %s: %w", id, ErrCancelRequested)
+		}
+		return job, &StateError{Id: id, State: job.State, Want: "pending, failed or processing"}
+	}
+	return c.Get(ctx, id)
+}
+
+// RetryOptions change a dead job as it is retried.
+type RetryOptions struct {
+	// MaxRetries replaces the job's own limit when set
+	MaxRetries *int
+}
+
+// RetryDead moves a job from the dead letter queue back to pending with
+// its attempts reset. A job that isn't dead fails with a *StateError.
+func (c *Client) RetryDead(ctx context.Context, id string, opts RetryOptions) (Job, error) {
+	var maxRetries sql.NullInt64
+	if opts.MaxRetries != nil {
+		if *opts.MaxRetries < 0 {
+			return Job{}, errors.New("max_retries must be >= 0")
+		}
+		maxRetries = sql.NullInt64{Int64: int64(*opts.MaxRetries), Valid: true}
+	}
+
+	job, err := c.Get(ctx, id)
+	if err != nil {
+		return Job{}, err
+	}
+	if job.State != "dead" {
+		return job, &StateError{Id: id, State: job.State, Want: "dead"}
+	}
+
+	ok, err := c.st.RetryDead(id, maxRetries, c.actor)
+	if err != nil {
+		return Job{}, err
+	}
+
+	job, err = c.Get(ctx, id)
+	if err == nil && !ok {
+		err = &StateError{Id: id, State: job.State, Want: "dead"}
+	}
+	return job, err
+}
@@ -0,0 +1,454 @@
+package client
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"queuectl/internal/store"
+)
+
+// JobSpec describes a job to enqueue. It needs a Command, a Type, or both:
+// a worker that has a handler for Type calls it with Payload, and any other
+// worker runs Command. State, Attempts, Created_at and Updated_at are set
+// by queuectl and must be left empty; Enqueue returns them filled in.
+// Durations are Go durations, e.g. "30s" or "5m".
+type JobSpec struct {
+	Id      string `json:"id" yaml:"id"`
+	Queue   string `json:"queue" yaml:"queue"`
+	Command string `json:"command,omitempty" yaml:"command,omitempty"`
+	Type    string `json:"type,omitempty" yaml:"type,omitempty"`
+
+	// Payload is stored as JSON, so it can be any value encoding/json
+	// marshals, e.g. a struct or a json.RawMessage
+	Payload any `json:"payload,omitempty" yaml:"payload,omitempty"`
+
+	State        string            `json:"state" yaml:"state"`
+	Attempts     int               `json:"attempts" yaml:"attempts"`
+	Max_retries  *int              `json:"max_retries" yaml:"max_retries"`
+	Priority     *int              `json:"priority" yaml:"priority"`
+	Timeout      string            `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Backoff      string            `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+	Backoff_base string            `json:"backoff_base,omitempty" yaml:"backoff_base,omitempty"`
+	Backoff_max  string            `json:"backoff_max,omitempty" yaml:"backoff_max,omitempty"`
+	Retry_on     []int             `json:"retry_on,omitempty" yaml:"retry_on,omitempty"`
+	Fail_fast_on []int             `json:"fail_fast_on,omitempty" yaml:"fail_fast_on,omitempty"`
+	Run_at       string            `json:"run_at,omitempty" yaml:"run_at,omitempty"`
+	Env          map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+	Tags         []string          `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Created_at   string            `json:"created_at" yaml:"created_at"`
+	Updated_at   string            `json:"updated_at" yaml:"updated_at"`
+}
+
+// Job is a stored job: the spec it was enqueued with plus what happened to
+// it since.
+type Job struct {
+	JobSpec `yaml:",inline"`
+
+	Next_run_at         string `json:"next_run_at,omitempty" yaml:"next_run_at,omitempty"`
+	Worker_id           string `json:"worker_id,omitempty" yaml:"worker_id,omitempty"`
+	Failure_reason      string `json:"failure_reason,omitempty" yaml:"failure_reason,omitempty"`
+	Cancel_requested_at string `json:"cancel_requested_at,omitempty" yaml:"cancel_requested_at,omitempty"`
+	Cancelled_at        string `json:"cancelled_at,omitempty" yaml:"cancelled_at,omitempty"`
+	Cancelled_by        string `json:"cancelled_by,omitempty" yaml:"cancelled_by,omitempty"`
+	Cancel_reason       string `json:"cancel_reason,omitempty" yaml:"cancel_reason,omitempty"`
+}
+
+// DefaultQueue is the queue of jobs enqueued without one.
+const DefaultQueue = "default"
+
+var queueNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// tagPattern is what a job tag may look like, e.g. "customer:42".
+var tagPattern = regexp.MustCompile(`^[A-Za-z0-9_.:-]+$`)
+
+// ValidateQueueName checks that name can be used as a queue name.
+func ValidateQueueName(name string) error {
+	if !queueNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid queue name %q (use letters, digits, '.', '_' or '-')", name)
+	}
+	return nil
+}
+
+// BackoffStrategies lists the accepted values of backoff-strategy and of a
+// job's backoff field.
+var BackoffStrategies = []string{"fixed", "linear", "exponential", "full-jitter", "decorrelated-jitter"}
+
+// ValidateBackoffStrategy checks that name is one of BackoffStrategies.
+func ValidateBackoffStrategy(name string) error {
+	if !slices.Contains(BackoffStrategies, name) {
+		return fmt.Errorf("invalid backoff strategy %q (use one of: %s)", name, strings.Join(BackoffStrategies, ", "))
+	}
+	return nil
+}
+
+// ParseExitCodes parses a comma-separated list of exit codes such as
+// "1,75". An empty string is an empty list.
+func ParseExitCodes(s string) ([]int, error) {
+	var codes []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		code, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exit code %q", part)
+		}
+		codes = append(codes, code)
+	}
+	return codes, validateExitCodes(codes)
+}
+
+func validateExitCodes(codes []int) error {
+	for _, code := range codes {
+		if code < 1 || code > 255 {
+			return fmt.Errorf("invalid exit code %d (must be 1-255)", code)
+		}
+	}
+	return nil
+}
+
+// FormatExitCodes is the inverse of ParseExitCodes.
+func FormatExitCodes(codes []int) string {
+	parts := make([]string, len(codes))
+	for i, code := range codes {
+		parts[i] = strconv.Itoa(code)
+	}
+	return strings.Join(parts, ",")
+}
+
+// NewJobID returns a random id like the ones Enqueue gives jobs without
+// one.
+func NewJobID() string {
+	charset := "abcdefghijklmnopqrstuvwxyz1234567890"
+	id := ""
+	for i := 0; i < 8; i++ {
+		id += string(charset[rand.Intn(len(charset))])
+	}
+	return id
+}
+
+// InvalidJob is a spec that failed validation and its position in the
+// batch.
+type InvalidJob struct {
+	Index int
+	Err   error
+}
+
+// InvalidJobsError is returned when any spec of a batch is invalid. Nothing
+// was enqueued.
+type InvalidJobsError struct {
+	Jobs []InvalidJob
+}
+
+func (e *InvalidJobsError) Error() string {
+	if len(e.Jobs) == 1 {
+		return e.Jobs[0].Err.Error()
+	}
+	return fmt.Sprintf("%d invalid jobs, the first: job %d: %v", len(e.Jobs), e.Jobs[0].Index+1, e.Jobs[0].Err)
+}
+
+// DuplicateIdError is the error of a spec whose id an earlier spec of the
+// same batch, at First, already uses.
+type DuplicateIdError struct {
+	Id    string
+	First int
+}
+
+func (e *DuplicateIdError) Error() string {
+	return fmt.Sprintf("duplicate id %q (also used by job %d)", e.Id, e.First+1)
+}
+
+// Validate checks spec the way Enqueue does, without storing anything.
+func (spec JobSpec) Validate() error {
+	_, err := prepareJob(spec)
+	return err
+}
+
+// ValidateBatch checks specs the way EnqueueBatch does, without storing
+// anything. It returns nil or an *InvalidJobsError.
+func ValidateBatch(specs []JobSpec) error {
+	_, err := prepareJobs(specs)
+	return err
+}
+
+// prepareJobs validates a batch; ids must be unique within it.
+func prepareJobs(specs []JobSpec) ([]preparedJob, error) {
+	var jobs []preparedJob
+	var invalid []InvalidJob
+	seenIds := map[string]int{}
+
+	for i, spec := range specs {
+		job, err := prepareJob(spec)
+		if err != nil {
+			invalid = append(invalid, InvalidJob{Index: i, Err: err})
+			continue
+		}
+		if first, ok := seenIds[job.Spec.Id]; ok {
+			invalid = append(invalid, InvalidJob{Index: i, Err: &DuplicateIdError{Id: job.Spec.Id, First: first}})
+			continue
+		}
+		seenIds[job.Spec.Id] = i
+		jobs = append(jobs, job)
+	}
+
+	if len(invalid) > 0 {
+		return nil, &InvalidJobsError{Jobs: invalid}
+	}
+	return jobs, nil
+}
+
+// preparedJob is a validated JobSpec together with the column values
+// derived from it.
+type preparedJob struct {
+	Spec           JobSpec
+	TimeoutSeconds sql.NullInt64
+	NextRunAt      sql.NullString
+	Env            sql.NullString
+	Tags           sql.NullString
+
+	// Backoff settings given on the job; NULL falls back to config
+	BackoffStrategy    sql.NullString
+	BackoffBaseSeconds sql.NullInt64
+	BackoffMaxSeconds  sql.NullInt64
+
+	// Exit code lists given on the job; NULL falls back to config
+	RetryOn    sql.NullString
+	FailFastOn sql.NullString
+
+	Type    sql.NullString
+	Payload sql.NullString
+}
+
+// prepareJob validates a submitted spec and fills in the fields queuectl
+// owns (id when absent, state, attempts and timestamps). Jobs without
+// run_at are due right away.
+func prepareJob(spec JobSpec) (preparedJob, error) {
+	job := preparedJob{Spec: spec}
+
+	if strings.TrimSpace(spec.Command) == "" && spec.Type == "" {
+		return job, errors.New("command or type is required")
+	}
+	if spec.State != "" || spec.Attempts != 0 || spec.Created_at != "" || spec.Updated_at != "" {
+		return job, errors.New("state, attempts, created_at and updated_at are set by queuectl")
+	}
+	if spec.Queue == "" {
+		job.Spec.Queue = DefaultQueue
+	} else if err := ValidateQueueName(spec.Queue); err != nil {
+		return job, err
+	}
+	if spec.Max_retries != nil && *spec.Max_retries < 0 {
+		return job, errors.New("max_retries must be >= 0")
+	}
+
+	if spec.Timeout != "" {
+		timeout, err := time.ParseDuration(spec.Timeout)
+		if err != nil || timeout < 0 || (timeout > 0 && timeout < time.Second) {
+			return job, fmt.Errorf("invalid timeout %q (use e.g. 30s, 5m; 0 disables)", spec.Timeout)
+		}
+		job.TimeoutSeconds = sql.NullInt64{Int64: int64(timeout / time.Second), Valid: true}
+	}
+
+	if spec.Backoff != "" {
+		if err := ValidateBackoffStrategy(spec.Backoff); err != nil {
+			return job, err
+		}
+		job.BackoffStrategy = sql.NullString{String: spec.Backoff, Valid: true}
+	}
+	for _, field := range []struct {
+		name  string
+		value string
+		dest  *sql.NullInt64
+	}{
+		{"backoff_base", spec.Backoff_base, &job.BackoffBaseSeconds},
+		{"backoff_max", spec.Backoff_max, &job.BackoffMaxSeconds},
+	} {
+		if field.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(field.value)
+		if err != nil || d < time.Second {
+			return job, fmt.Errorf("invalid %s %q (use e.g. 5s, 10m; at least 1s)", field.name, field.value)
+		}
+		*field.dest = sql.NullInt64{Int64: int64(d / time.Second), Valid: true}
+	}
+
+	if spec.Retry_on != nil {
+		if err := validateExitCodes(spec.Retry_on); err != nil {
+			return job, fmt.Errorf("retry_on: %w", err)
+		}
+		job.RetryOn = sql.NullString{String: FormatExitCodes(spec.Retry_on), Valid: true}
+	}
+	if spec.Fail_fast_on != nil {
+		if err := validateExitCodes(spec.Fail_fast_on); err != nil {
+			return job, fmt.Errorf("fail_fast_on: %w", err)
+		}
+		job.FailFastOn = sql.NullString{String: FormatExitCodes(spec.Fail_fast_on), Valid: true}
+	}
+
+	if spec.Type != "" {
+		if !tagPattern.MatchString(spec.Type) {
+			return job, fmt.Errorf("invalid type %q (use letters, digits, '.', '_', ':' or '-')", spec.Type)
+		}
+		job.Type = sql.NullString{String: spec.Type, Valid: true}
+	}
+	if spec.Payload != nil {
+		if raw, ok := spec.Payload.(json.RawMessage); ok && !json.Valid(raw) {
+			return job, errors.New("payload is not valid JSON")
+		}
+		payloadJSON, err := json.Marshal(spec.Payload)
+		if err != nil {
+			return job, fmt.Errorf("invalid payload: %w", err)
+		}
+		job.Payload = sql.NullString{String: string(payloadJSON), Valid: true}
+	}
+
+	if spec.Run_at != "" {
+		runAt, err := time.Parse(time.RFC3339, spec.Run_at)
+		if err != nil {
+			return job, fmt.Errorf("invalid run_at %q (use RFC3339, e.g. 2026-11-01T03:00:00Z)", spec.Run_at)
+		}
+		job.NextRunAt = sql.NullString{String: formatTime(runAt), Valid: true}
+	}
+
+	if len(spec.Env) > 0 {
+		for key := range spec.Env {
+			if key == "" || strings.ContainsAny(key, "=\x00") {
+				return job, fmt.Errorf("invalid env variable name %q", key)
+			}
+		}
+		envJSON, err := json.Marshal(spec.Env)
+		if err != nil {
+			return job, err
+		}
+		job.Env = sql.NullString{String: string(envJSON), Valid: true}
+	}
+
+	if len(spec.Tags) > 0 {
+		var tags []string
+		for _, tag := range spec.Tags {
+			if !tagPattern.MatchString(tag) {
+				return job, fmt.Errorf("invalid tag %q (use letters, digits, '.', '_', ':' or '-')", tag)
+			}
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		tagsJSON, err := json.Marshal(tags)
+		if err != nil {
+			return job, err
+		}
+		job.Spec.Tags = tags
+		job.Tags = sql.NullString{String: string(tagsJSON), Valid: true}
+	}
+
+	if job.Spec.Id == "" {
+		job.Spec.Id = NewJobID()
+	}
+
+	now := formatTime(time.Now())
+	job.Spec.State = "pending"
+	job.Spec.Created_at = now
+	job.Spec.Updated_at = now
+	if !job.NextRunAt.Valid {
+		job.NextRunAt = sql.NullString{String: now, Valid: true}
+	}
+
+	return job, nil
+}
+
+// storeJob is the row a prepared job is stored as. Max_retries must have
+// been resolved by the caller; a job without a Priority gets 0.
+func (job preparedJob) storeJob(actor string) store.NewJob {
+	priority := 0
+	if job.Spec.Priority != nil {
+		priority = *job.Spec.Priority
+	}
+	return store.NewJob{
+		Id:                 job.Spec.Id,
+		Queue:              job.Spec.Queue,
+		Command:            job.Spec.Command,
+		State:              job.Spec.State,
+		Attempts:           job.Spec.Attempts,
+		MaxRetries:         *job.Spec.Max_retries,
+		Priority:           priority,
+		CreatedAt:          job.Spec.Created_at,
+		UpdatedAt:          job.Spec.Updated_at,
+		NextRunAt:          job.NextRunAt,
+		TimeoutSeconds:     job.TimeoutSeconds,
+		Env:                job.Env,
+		Tags:               job.Tags,
+		BackoffStrategy:    job.BackoffStrategy,
+		BackoffBaseSeconds: job.BackoffBaseSeconds,
+		BackoffMaxSeconds:  job.BackoffMaxSeconds,
+		RetryOn:            job.RetryOn,
+		FailFastOn:         job.FailFastOn,
+		Type:               job.Type,
+		Payload:            job.Payload,
+		Actor:              actor,
+	}
+}
+
+// newJob converts a stored job.
+func newJob(j store.Job) Job {
+	job := Job{JobSpec: JobSpec{
+		Id:         j.Id,
+		Queue:      j.Queue,
+		Command:    j.Command,
+		Type:       j.Type.String,
+		State:      j.State,
+		Attempts:   j.Attempts,
+		Created_at: j.CreatedAt,
+		Updated_at: j.UpdatedAt,
+	}}
+
+	maxRetries, priority := j.MaxRetries, j.Priority
+	job.Max_retries = &maxRetries
+	job.Priority = &priority
+	job.Timeout = secondsString(j.TimeoutSeconds)
+	job.Backoff = j.BackoffStrategy.String
+	job.Backoff_base = secondsString(j.BackoffBaseSeconds)
+	job.Backoff_max = secondsString(j.BackoffMaxSeconds)
+	if j.RetryOn.Valid {
+		job.Retry_on, _ = ParseExitCodes(j.RetryOn.String)
+	}
+	if j.FailFastOn.Valid {
+		job.Fail_fast_on, _ = ParseExitCodes(j.FailFastOn.String)
+	}
+	if j.Env.Valid {
+		json.Unmarshal([]byte(j.Env.String), &job.Env)
+	}
+	if j.Tags.Valid {
+		json.Unmarshal([]byte(j.Tags.String), &job.Tags)
+	}
+	if j.Payload.Valid {
+		json.Unmarshal([]byte(j.Payload.String), &job.Payload)
+	}
+
+	job.Next_run_at = j.NextRunAt.String
+	job.Worker_id = j.WorkerId.String
+	job.Failure_reason = j.FailureReason.String
+	job.Cancel_requested_at = j.CancelRequestedAt.String
+	job.Cancelled_at = j.CancelledAt.String
+	job.Cancelled_by = j.CancelledBy.String
+	job.Cancel_reason = j.CancelReason.String
+
+	return job
+}
+
+// secondsString formats a stored number of seconds the way a job spec
+// gives durations, e.g. "5m0s".
+func secondsString(seconds sql.NullInt64) string {
+	if !seconds.Valid {
+		return ""
+	}
+	return (time.Duration(seconds.Int64) * time.Second).String()
+}
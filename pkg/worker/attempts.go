@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"queuectl/internal/store"
+)
+
+// tailBuffer is an io.Writer that keeps only the last limit bytes written
+// to it. The worker flushes it to job_attempts while the command is still
+// running, so it must be safe for concurrent use.
+type tailBuffer struct {
+	mu        sync.Mutex
+	buf       []byte
+	limit     int
+	truncated int
+}
+
+func newTailBuffer(limit int) *tailBuffer {
+	return &tailBuffer{limit: limit}
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.buf = append(t.buf, p...)
+	if over := len(t.buf) - t.limit; over > 0 {
+		t.buf = append(t.buf[:0], t.buf[over:]...)
+		t.truncated += over
+	}
+
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.truncated > 0 {
+		return fmt.Sprintf("...[%d bytes truncated]...\n%s", t.truncated, t.buf)
+	}
+	return string(t.buf)
+}
+
+// flushAttemptOutput stores the output captured so far, letting
+// `queuectl logs --follow` show a command's progress before it exits.
+func flushAttemptOutput(st store.Store, rowId int64, stdout, stderr *tailBuffer) {
+	st.SaveAttemptOutput(rowId, stdout.String(), stderr.String())
+}
+
+// streamAttemptOutput calls flushAttemptOutput every second until stop is
+// closed.
+func streamAttemptOutput(st store.Store, rowId int64, stdout, stderr *tailBuffer, stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			flushAttemptOutput(st, rowId, stdout, stderr)
+		}
+	}
+}
+
+func finishAttempt(st store.Store, rowId int64, result execResult, stdout, stderr *tailBuffer) error {
+	return st.FinishAttempt(rowId, store.AttemptResult{
+		ExitCode: result.ExitCode,
+		Signal:   result.Signal,
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+	})
+}
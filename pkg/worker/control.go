@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// workerControl is a worker's view of the control messages sent to it:
+//
+//	stop   - stop now; a running job gets SIGTERM and goes back to pending
+//	drain  - finish the running job, then exit
+//	pause  - keep running the current job but claim no new ones
+//	resume - undo pause
+type workerControl struct {
+	paused   atomic.Bool
+	draining atomic.Bool
+
+	// The job being run right now, if any
+	job atomic.Pointer[runningJob]
+}
+
+// runningJob lets the control watcher kill a job that was cancelled while
+// it ran.
+type runningJob struct {
+	Id        string
+	sd        *shutdown
+	cancelled atomic.Bool
+}
+
+// cancelGracePeriod is how long a cancelled job has to exit after SIGTERM
+// before it is killed.
+const cancelGracePeriod = 5 * time.Second
+
+// watchControl polls for control messages addressed to workerId every
+// second, applies them and acknowledges each one, until stop is closed.
+// A stop message begins the worker's own shutdown wsd and forces it after
+// ShutdownTimeout.
+func (w *Worker) watchControl(workerId string, ctl *workerControl, wsd *shutdown, stop <-chan struct{}) {
+	st := w.st
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		messages, err := st.WorkerCommands(workerId)
+		if err == nil {
+			for _, m := range messages {
+				switch m.Command {
+				case "pause":
+					ctl.paused.Store(true)
+					st.SetWorkerState(workerId, "paused")
+				case "resume":
+					ctl.paused.Store(false)
+					st.SetWorkerState(workerId, "running")
+				case "drain":
+					ctl.draining.Store(true)
+					st.SetWorkerState(workerId, "draining")
+				case "stop":
+					st.SetWorkerState(workerId, "stopping")
+					wsd.begin(syscall.SIGTERM)
+					go func() {
+						time.Sleep(w.opts.ShutdownTimeout)
+						wsd.forceStop()
+					}()
+				}
+
+				st.AckWorkerCommand(m.Id)
+				w.logf("[%s] 📨 Received %s\n", workerId, m.Command)
+			}
+		}
+
+		if running := ctl.job.Load(); running != nil && !running.cancelled.Load() {
+			if requested, _ := st.CancelRequested(running.Id, workerId); requested {
+				w.logf("[%s] 🚫 Job %s was cancelled → terminating it\n", workerId, running.Id)
+				running.cancelled.Store(true)
+				running.sd.begin(syscall.SIGTERM)
+				go func() {
+					time.Sleep(cancelGracePeriod)
+					running.sd.forceStop()
+				}()
+			}
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// execResult describes how a job command or handler ended. ExitCode is -1
+// when the process was killed by a signal or could not be started at all.
+// Interrupted is set when the job failed after the worker began shutting
+// down. Handler names the job type for jobs run by a handler.
+type execResult struct {
+	ExitCode    int
+	Signal      string
+	TimedOut    bool
+	Interrupted bool
+	Handler     string
+	Err         error
+}
+
+// describeResult summarizes how an attempt ended, for the job's history.
+func describeResult(result execResult, timeout time.Duration) string {
+	switch {
+	case result.TimedOut:
+		return fmt.Sprintf("timed out after %v", timeout)
+	case result.Signal != "":
+		return "killed by " + result.Signal
+	case result.Handler != "" && result.Err != nil:
+		return fmt.Sprintf("%s handler failed with exit code %d: %v", result.Handler, result.ExitCode, result.Err)
+	}
+	return fmt.Sprintf("exit code %d", result.ExitCode)
+}
+
+// runCommand runs a job command through the platform shell with env added
+// to the worker's own environment, streaming its output into stdout and
+// stderr. A positive timeout puts a deadline on the
+// whole process group: when it expires the shell and everything it started
+// are killed and TimedOut is reported.
+//
+// When sd begins, the shutdown signal is forwarded to the process group so
+// the command can clean up; when sd is forced, the group is killed.
+func runCommand(command string, env []string, timeout time.Duration, stdout, stderr io.Writer, sd *shutdown) execResult {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var execCmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		execCmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		execCmd = exec.CommandContext(ctx, "bash", "-c", command)
+	}
+
+	if len(env) > 0 {
+		execCmd.Env = append(os.Environ(), env...)
+	}
+	execCmd.Stdout = stdout
+	execCmd.Stderr = stderr
+
+	setProcessGroup(execCmd)
+	execCmd.Cancel = func() error {
+		return killProcessGroup(execCmd)
+	}
+	// Don't wait forever on descendants that escaped the group kill
+	execCmd.WaitDelay = 5 * time.Second
+
+	result := execResult{ExitCode: -1}
+	result.Err = execCmd.Start()
+	if result.Err != nil {
+		return result
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		select {
+		case <-exited:
+			return
+		case <-sd.done:
+			signalProcessGroup(execCmd, sd.signal)
+		}
+		select {
+		case <-exited:
+		case <-sd.force:
+			killProcessGroup(execCmd)
+		}
+	}()
+
+	result.Err = execCmd.Wait()
+	close(exited)
+
+	if execCmd.ProcessState != nil {
+		result.ExitCode = execCmd.ProcessState.ExitCode()
+		result.Signal = exitSignal(execCmd.ProcessState)
+	}
+
+	if result.Err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		result.TimedOut = true
+	}
+	if result.Err != nil && sd.stopping() {
+		result.Interrupted = true
+	}
+
+	return result
+}
@@ -0,0 +1,29 @@
+//go:build windows
+
+package worker
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the process and all of its children; Windows has
+// no process groups in the unix sense, so taskkill walks the tree.
+func killProcessGroup(cmd *exec.Cmd) error {
+	return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+}
+
+// signalProcessGroup does nothing: a console Ctrl-C already reaches every
+// process attached to the console, and a service stop has no signal to
+// forward. Unfinished commands are killed when the shutdown is forced.
+func signalProcessGroup(cmd *exec.Cmd, sig os.Signal) error {
+	return nil
+}
+
+// exitSignal always reports nothing: Windows processes don't die of signals.
+func exitSignal(state *os.ProcessState) string {
+	return ""
+}
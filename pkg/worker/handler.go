@@ -0,0 +1,106 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// HandlerFunc runs a job in-process. payload is the job's JSON payload, nil
+// when it was enqueued without one. Returning nil completes the job; an
+// error fails the attempt, which is then retried or moved to the dead
+// letter queue like a command that exited non-zero.
+//
+// ctx is cancelled when the job times out, is cancelled with "queuectl
+// cancel" or the worker shuts down. A handler should return soon after,
+// as unlike a command it can't be killed.
+type HandlerFunc func(ctx context.Context, payload json.RawMessage) error
+
+// ExitError gives a handler error an exit code, for the retry-on,
+// fail-fast-on and reschedule-exit-code settings to match. Any error with
+// an ExitCode method works the same; errors without one count as exit
+// code 1.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit code %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns Code.
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// Handle registers h for jobs of jobType. Call it before Run: a worker only
+// claims typed jobs whose type has a handler, and a handler registered
+// again replaces the earlier one.
+func (w *Worker) Handle(jobType string, h HandlerFunc) {
+	w.handlers[jobType] = h
+}
+
+// runHandler calls a job's handler with its payload, writing the error it
+// returns to stderr. A positive timeout is put on ctx, and ctx is also
+// cancelled when sd begins; once sd is forced the handler is still waited
+// for, as there is nothing to kill. A panic fails the attempt like an
+// error.
+func runHandler(h HandlerFunc, jobType string, payload json.RawMessage, timeout time.Duration, stderr io.Writer, sd *shutdown) execResult {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+		defer cancelTimeout()
+	}
+
+	go func() {
+		select {
+		case <-sd.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	result := execResult{Handler: jobType}
+	result.Err = callHandler(ctx, h, payload)
+	if result.Err == nil {
+		return result
+	}
+
+	fmt.Fprintln(stderr, result.Err)
+	result.ExitCode = 1
+	var coded interface{ ExitCode() int }
+	if errors.As(result.Err, &coded) {
+		result.ExitCode = coded.ExitCode()
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		result.TimedOut = true
+	}
+	if sd.stopping() {
+		result.Interrupted = true
+	}
+
+	return result
+}
+
+func callHandler(ctx context.Context, h HandlerFunc, payload json.RawMessage) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return h(ctx, payload)
+}
@@ -0,0 +1,357 @@
+// Command handlercheck runs a pkg/worker Worker with Go handlers against
+// real databases and checks what happens to the jobs they run:
+//
+//	go run ./pkg/worker/internal/handlercheck data/test.db postgres://localhost/queuectl_test
+//
+// Each argument is a database as --db takes it. It is created and migrated
+// if needed, and every check uses a queue of its own. The exit status is 1
+// if any check failed on any database.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"queuectl/internal/db"
+	"queuectl/internal/store"
+	"queuectl/pkg/client"
+	"queuectl/pkg/worker"
+
+	"github.com/google/uuid"
+)
+
+// env is what a check needs: the database, a client for it, a store for
+// what the client doesn't show (attempt output), and a queue no other
+// check or run uses.
+type env struct {
+	dsn   string
+	c     *client.Client
+	st    store.Store
+	queue string
+}
+
+type check struct {
+	name string
+	run  func(e env) error
+}
+
+var checks = []check{
+	{"handler gets its payload", checkPayload},
+	{"handler error retries, then dead", checkRetries},
+	{"panic fails the attempt", checkPanic},
+	{"fail-fast exit code", checkFailFast},
+	{"typed job with a command", checkTypedCommand},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: handlercheck <database>...")
+		os.Exit(2)
+	}
+
+	failed := 0
+	for _, dsn := range os.Args[1:] {
+		failed += runChecks(dsn)
+	}
+
+	if failed > 0 {
+		fmt.Printf("\n❌ %d check(s) failed\n", failed)
+		os.Exit(1)
+	}
+	fmt.Println("\n✅ All checks passed")
+}
+
+// runChecks runs every check against dsn and returns how many failed.
+func runChecks(dsn string) int {
+	conn, schema, err := db.Connect(dsn, db.Options{Create: true})
+	if err == nil {
+		err = schema.Migrate(conn)
+		conn.Close()
+	}
+	var st store.Store
+	if err == nil {
+		st, err = store.Open(dsn, db.Options{})
+	}
+	if err != nil {
+		fmt.Printf("❌ %s: %v\n", db.Redact(dsn), err)
+		return 1
+	}
+	defer st.Close()
+	c, err := client.Open(dsn, client.Options{Actor: "handlercheck"})
+	if err != nil {
+		fmt.Printf("❌ %s: %v\n", db.Redact(dsn), err)
+		return 1
+	}
+	defer c.Close()
+
+	fmt.Printf("===== %s =====\n", db.Redact(dsn))
+
+	run := uuid.NewString()[:8]
+	failed := 0
+	for i, ch := range checks {
+		e := env{dsn: dsn, c: c, st: st, queue: fmt.Sprintf("handlercheck-%s-%d", run, i)}
+		if err := ch.run(e); err != nil {
+			fmt.Printf("  FAIL  %s: %v\n", ch.name, err)
+			failed++
+			continue
+		}
+		fmt.Printf("  ok    %s\n", ch.name)
+	}
+	return failed
+}
+
+// enqueue adds spec to the check's queue and returns its id.
+func (e env) enqueue(spec client.JobSpec) (string, error) {
+	spec.Queue = e.queue
+	job, err := e.c.Enqueue(context.Background(), spec)
+	if err != nil {
+		return "", fmt.Errorf("enqueue: %w", err)
+	}
+	return job.Id, nil
+}
+
+// work runs a Worker for the check's queue with handlers until every job
+// in want has reached the state it maps to, and returns those jobs.
+func (e env) work(handlers map[string]worker.HandlerFunc, want map[string]string) (map[string]client.Job, error) {
+	w, err := worker.Open(e.dsn, worker.Options{
+		Queues:       []worker.Queue{{Name: e.queue}},
+		PollInterval: 100 * time.Millisecond,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("open worker: %w", err)
+	}
+	defer w.Close()
+	for jobType, h := range handlers {
+		w.Handle(jobType, h)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	var runErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		runErr = w.Run(ctx)
+	}()
+
+	jobs, err := e.waitFor(want, 15*time.Second)
+	cancel()
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	if runErr != nil {
+		return nil, fmt.Errorf("run: %w", runErr)
+	}
+	return jobs, nil
+}
+
+func (e env) waitFor(want map[string]string, timeout time.Duration) (map[string]client.Job, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		jobs := map[string]client.Job{}
+		for id, state := range want {
+			job, err := e.c.Get(context.Background(), id)
+			if err != nil {
+				return nil, fmt.Errorf("get %s: %w", id, err)
+			}
+			if job.State != state {
+				if time.Now().After(deadline) {
+					return nil, fmt.Errorf("job %s is %s after %v, want %s", id, job.State, timeout, state)
+				}
+				break
+			}
+			jobs[id] = job
+		}
+		if len(jobs) == len(want) {
+			return jobs, nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// attempt is what a job_attempts row recorded.
+type attempt struct {
+	ExitCode int
+	Stdout   string
+	Stderr   string
+}
+
+func (e env) attempts(id string) ([]attempt, error) {
+	stored, err := e.st.JobAttempts(id, 0)
+	if err != nil {
+		return nil, fmt.Errorf("attempts of %s: %w", id, err)
+	}
+
+	var attempts []attempt
+	for _, a := range stored {
+		exitCode := -1
+		if a.ExitCode.Valid {
+			exitCode = int(a.ExitCode.Int64)
+		}
+		attempts = append(attempts, attempt{ExitCode: exitCode, Stdout: a.Stdout.String, Stderr: a.Stderr.String})
+	}
+	return attempts, nil
+}
+
+// retries is a Max_retries: how many attempts a job gets in all.
+func retries(n int) *int {
+	return &n
+}
+
+type email struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+}
+
+func checkPayload(e env) error {
+	id, err := e.enqueue(client.JobSpec{Type: "send-email", Payload: email{To: "ops", Subject: "hello"}})
+	if err != nil {
+		return err
+	}
+
+	var got email
+	calls := 0
+	_, err = e.work(map[string]worker.HandlerFunc{
+		"send-email": func(ctx context.Context, payload json.RawMessage) error {
+			calls++
+			return json.Unmarshal(payload, &got)
+		},
+	}, map[string]string{id: "completed"})
+	if err != nil {
+		return err
+	}
+	if calls != 1 || got != (email{To: "ops", Subject: "hello"}) {
+		return fmt.Errorf("handler ran %d time(s) and got %+v", calls, got)
+	}
+	return nil
+}
+
+func checkRetries(e env) error {
+	id, err := e.enqueue(client.JobSpec{Type: "flaky", Max_retries: retries(2), Backoff: "fixed", Backoff_base: "1s"})
+	if err != nil {
+		return err
+	}
+
+	calls := 0
+	jobs, err := e.work(map[string]worker.HandlerFunc{
+		"flaky": func(ctx context.Context, payload json.RawMessage) error {
+			calls++
+			return fmt.Errorf("attempt %d failed", calls)
+		},
+	}, map[string]string{id: "dead"})
+	if err != nil {
+		return err
+	}
+	if calls != 2 || jobs[id].Attempts != 2 || jobs[id].Failure_reason != "failed" {
+		return fmt.Errorf("handler ran %d time(s), job has %d attempts and reason %q",
+			calls, jobs[id].Attempts, jobs[id].Failure_reason)
+	}
+
+	attempts, err := e.attempts(id)
+	if err != nil {
+		return err
+	}
+	if len(attempts) != 2 || attempts[1].ExitCode != 1 || attempts[1].Stderr != "attempt 2 failed\n" {
+		return fmt.Errorf("attempts recorded %+v", attempts)
+	}
+	return nil
+}
+
+func checkPanic(e env) error {
+	panicked, err := e.enqueue(client.JobSpec{Type: "explode", Max_retries: retries(1)})
+	if err != nil {
+		return err
+	}
+	// Enqueued later, so it runs after the panic: the worker must survive
+	after, err := e.enqueue(client.JobSpec{Type: "noop"})
+	if err != nil {
+		return err
+	}
+
+	_, err = e.work(map[string]worker.HandlerFunc{
+		"explode": func(ctx context.Context, payload json.RawMessage) error {
+			panic("boom")
+		},
+		"noop": func(ctx context.Context, payload json.RawMessage) error {
+			return nil
+		},
+	}, map[string]string{panicked: "dead", after: "completed"})
+	if err != nil {
+		return err
+	}
+
+	attempts, err := e.attempts(panicked)
+	if err != nil {
+		return err
+	}
+	if len(attempts) != 1 || attempts[0].ExitCode != 1 || !strings.Contains(attempts[0].Stderr, "panic: boom") {
+		return fmt.Errorf("attempts recorded %+v", attempts)
+	}
+	return nil
+}
+
+func checkFailFast(e env) error {
+	id, err := e.enqueue(client.JobSpec{Type: "charge", Max_retries: retries(3), Fail_fast_on: []int{3}})
+	if err != nil {
+		return err
+	}
+
+	jobs, err := e.work(map[string]worker.HandlerFunc{
+		"charge": func(ctx context.Context, payload json.RawMessage) error {
+			return &worker.ExitError{Code: 3, Err: errors.New("card declined")}
+		},
+	}, map[string]string{id: "dead"})
+	if err != nil {
+		return err
+	}
+	if jobs[id].Attempts != 1 || jobs[id].Failure_reason != "non_retryable (exit code 3)" {
+		return fmt.Errorf("job has %d attempts and reason %q", jobs[id].Attempts, jobs[id].Failure_reason)
+	}
+
+	attempts, err := e.attempts(id)
+	if err != nil {
+		return err
+	}
+	if len(attempts) != 1 || attempts[0].ExitCode != 3 || attempts[0].Stderr != "card declined\n" {
+		return fmt.Errorf("attempts recorded %+v", attempts)
+	}
+	return nil
+}
+
+func checkTypedCommand(e env) error {
+	// No handler for "report": the worker runs the command, with the
+	// payload in $QUEUECTL_PAYLOAD
+	id, err := e.enqueue(client.JobSpec{
+		Type:    "report",
+		Command: `printf '%s' "$QUEUECTL_PAYLOAD"`,
+		Payload: map[string]int{"day": 7},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = e.work(map[string]worker.HandlerFunc{
+		"other": func(ctx context.Context, payload json.RawMessage) error {
+			return errors.New("the wrong handler ran")
+		},
+	}, map[string]string{id: "completed"})
+	if err != nil {
+		return err
+	}
+
+	attempts, err := e.attempts(id)
+	if err != nil {
+		return err
+	}
+	if len(attempts) != 1 || attempts[0].ExitCode != 0 || attempts[0].Stdout != `{"day":7}` {
+		return fmt.Errorf("attempts recorded %+v", attempts)
+	}
+	return nil
+}
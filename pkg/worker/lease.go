@@ -0,0 +1,30 @@
+package worker
+
+import (
+	"time"
+
+	"queuectl/internal/store"
+)
+
+// heartbeat refreshes the worker's Last_heartbeat and extends the lease on
+// whatever job it is processing until stop is closed. It runs in its own
+// goroutine so long-running commands keep their lease alive.
+func heartbeat(st store.Store, workerId string, lease time.Duration, stop <-chan struct{}) {
+	interval := lease / 3
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		st.Heartbeat(workerId, lease)
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Queue is a queue a worker takes jobs from. Weight is its share of polls
+// under the "weighted" strategy; below 1 counts as 1.
+type Queue struct {
+	Name   string
+	Weight int
+}
+
+// formatQueues writes queues back the way "worker --queues" takes them,
+// for the workers table.
+func formatQueues(queues []Queue) string {
+	parts := make([]string, len(queues))
+	for i, q := range queues {
+		parts[i] = q.Name
+		if q.Weight != 1 {
+			parts[i] += ":" + strconv.Itoa(q.Weight)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// queuePollOrder returns the order in which a worker should try its queues
+// on one poll. "ordered" always tries them as listed, so earlier queues
+// are drained first. "weighted" draws the order at random with each queue's
+// chance of coming first proportional to its weight. No subscriptions
+// means any queue, which is represented by a single empty name.
+func queuePollOrder(subs []Queue, strategy string) []string {
+	if len(subs) == 0 {
+		return []string{""}
+	}
+
+	order := make([]string, 0, len(subs))
+	if strategy != "weighted" {
+		for _, sub := range subs {
+			order = append(order, sub.Name)
+		}
+		return order
+	}
+
+	remaining := append([]Queue(nil), subs...)
+	for len(remaining) > 0 {
+		total := 0
+		for _, sub := range remaining {
+			total += sub.Weight
+		}
+
+		pick := rand.Intn(total)
+		for i, sub := range remaining {
+			if pick < sub.Weight {
+				order = append(order, sub.Name)
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+			pick -= sub.Weight
+		}
+	}
+
+	return order
+}
@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"slices"
+	"time"
+
+	"queuectl/internal/backoff"
+	"queuectl/internal/store"
+	"queuectl/pkg/client"
+)
+
+// retryPolicy decides what a failed attempt leads to, based on how the
+// command exited.
+type retryPolicy struct {
+	// RetryOn, when not empty, lists the only exit codes worth retrying
+	RetryOn []int
+	// FailFastOn lists exit codes that send the job straight to the DLQ
+	FailFastOn []int
+	// RescheduleCode, when not 0, asks for the job to run again later
+	// without using up an attempt
+	RescheduleCode int
+}
+
+// retryPolicy uses the exit code lists stored on a claimed job, falling
+// back to those configured for its queue.
+func (w *Worker) retryPolicy(job *store.ClaimedJob) retryPolicy {
+	var p retryPolicy
+
+	retryOn := job.RetryOn
+	if !retryOn.Valid {
+		retryOn.String = w.config(job.Queue, "retry-on").Value
+	}
+	failFastOn := job.FailFastOn
+	if !failFastOn.Valid {
+		failFastOn.String = w.config(job.Queue, "fail-fast-on").Value
+	}
+
+	// Both were validated when they were stored
+	p.RetryOn, _ = client.ParseExitCodes(retryOn.String)
+	p.FailFastOn, _ = client.ParseExitCodes(failFastOn.String)
+	p.RescheduleCode = w.config(job.Queue, "reschedule-exit-code").Int()
+
+	return p
+}
+
+// backoffPolicy applies the backoff settings stored on a claimed job on
+// top of those configured for its queue.
+func (w *Worker) backoffPolicy(job *store.ClaimedJob) backoff.Policy {
+	p := backoff.Policy{
+		Strategy: w.config(job.Queue, "backoff-strategy").Value,
+		Base:     time.Duration(w.config(job.Queue, "backoff-base").Int()) * time.Second,
+		Max:      time.Duration(w.config(job.Queue, "backoff-max").Int()) * time.Second,
+	}
+	if job.BackoffStrategy.Valid {
+		p.Strategy = job.BackoffStrategy.String
+	}
+	if job.BackoffBaseSeconds.Valid {
+		p.Base = time.Duration(job.BackoffBaseSeconds.Int64) * time.Second
+	}
+	if job.BackoffMaxSeconds.Valid {
+		p.Max = time.Duration(job.BackoffMaxSeconds.Int64) * time.Second
+	}
+	return p
+}
+
+func (p retryPolicy) reschedules(result execResult) bool {
+	return p.RescheduleCode != 0 && !result.TimedOut && result.ExitCode == p.RescheduleCode
+}
+
+// retryable reports whether a failed attempt may be retried. Timeouts and
+// commands killed by a signal have no exit code to judge and are always
+// retried.
+func (p retryPolicy) retryable(result execResult) bool {
+	if result.TimedOut || result.ExitCode < 0 {
+		return true
+	}
+	if slices.Contains(p.FailFastOn, result.ExitCode) {
+		return false
+	}
+	if len(p.RetryOn) > 0 {
+		return slices.Contains(p.RetryOn, result.ExitCode)
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// shutdown coordinates a graceful stop of all workers in this process.
+// Once it begins, workers stop claiming jobs and running commands get the
+// signal that started it; once it is forced, they are killed.
+type shutdown struct {
+	done  chan struct{}
+	force chan struct{}
+
+	beginOnce sync.Once
+	forceOnce sync.Once
+	signal    os.Signal
+
+	// Jobs handed back to the queue because they didn't finish in time
+	released atomic.Int64
+}
+
+func newShutdown() *shutdown {
+	return &shutdown{
+		done:  make(chan struct{}),
+		force: make(chan struct{}),
+	}
+}
+
+// child returns a shutdown that begins and is forced along with s but can
+// also be begun on its own, to stop a single worker or job. Call release
+// once the child is no longer used.
+func (s *shutdown) child() (c *shutdown, release func()) {
+	c = newShutdown()
+	released := make(chan struct{})
+
+	go func() {
+		select {
+		case <-s.done:
+			c.begin(s.signal)
+		case <-c.done:
+		case <-released:
+			return
+		}
+		select {
+		case <-s.force:
+			c.forceStop()
+		case <-c.force:
+		case <-released:
+		}
+	}()
+
+	return c, func() { close(released) }
+}
+
+func (s *shutdown) begin(sig os.Signal) {
+	s.beginOnce.Do(func() {
+		s.signal = sig
+		close(s.done)
+	})
+}
+
+func (s *shutdown) forceStop() {
+	s.forceOnce.Do(func() {
+		close(s.force)
+	})
+}
+
+func (s *shutdown) stopping() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
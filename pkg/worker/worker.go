@@ -0,0 +1,591 @@
+// Package worker runs queuectl jobs inside a Go program. A Worker claims
+// and runs jobs exactly as "queuectl worker" does, which is built on it:
+// the same leases, timeouts, retries, backoff, dead letter queue,
+// cancellation and control messages. Jobs whose type has a handler
+// registered with Handle run in-process; every other job runs its command
+// through the shell.
+//
+//	w, err := worker.Open("data/queue.db", worker.Options{Concurrency: 4})
+//	if err != nil {
+//		return err
+//	}
+//	defer w.Close()
+//
+//	w.Handle("send-email", func(ctx context.Context, payload json.RawMessage) error {
+//		var msg Email
+//		if err := json.Unmarshal(payload, &msg); err != nil {
+//			return err
+//		}
+//		return send(ctx, msg)
+//	})
+//
+//	err = w.Run(ctx)
+//
+// Jobs for a handler are enqueued with a type and a payload instead of a
+// command, e.g. queuectl enqueue --type send-email --payload '{"to":"ops"}'.
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"queuectl/internal/db"
+	"queuectl/internal/store"
+	"queuectl/pkg/client"
+)
+
+// Options tune a Worker. Zero values use the defaults of "queuectl
+// worker".
+type Options struct {
+	// Concurrency is how many jobs run at once, each in its own worker
+	// with its own id; default 1
+	Concurrency int
+
+	// Queues to take jobs from, all queues when empty. QueueStrategy is
+	// "ordered" (default: earlier queues are drained first) or "weighted".
+	Queues        []Queue
+	QueueStrategy string
+
+	// Limit is how many jobs each worker completes before it stops; 0
+	// means no limit
+	Limit int
+
+	// PollInterval is how long an idle worker waits before looking for
+	// jobs again; default 3s
+	PollInterval time.Duration
+
+	// ShutdownTimeout is how long running jobs get to finish once Run's
+	// context is done or a stop message arrives; default 30s
+	ShutdownTimeout time.Duration
+
+	// Log receives what the workers report; nil discards it. Verbose adds
+	// a line for every step of every job.
+	Log     io.Writer
+	Verbose bool
+
+	// BusyTimeout is how long an SQLite statement waits for another
+	// process's lock; 0 uses the default
+	BusyTimeout time.Duration
+}
+
+// InterruptedError is returned by Run when shutting down handed jobs that
+// hadn't finished back to the queue, for another worker to run again.
+type InterruptedError struct {
+	Jobs int64
+}
+
+func (e *InterruptedError) Error() string {
+	return fmt.Sprintf("%d unfinished job(s) were returned to the queue", e.Jobs)
+}
+
+// Worker runs jobs from one queue database. Register handlers with Handle,
+// then call Run once.
+type Worker struct {
+	st       store.Store
+	c        *client.Client
+	opts     Options
+	handlers map[string]HandlerFunc
+
+	sd           *shutdown
+	shutdownOnce sync.Once
+}
+
+// Open connects to the database dsn names, as client.Open does.
+func Open(dsn string, opts Options) (*Worker, error) {
+	st, err := store.Open(dsn, db.Options{BusyTimeout: opts.BusyTimeout})
+	if err != nil {
+		return nil, err
+	}
+	return New(st, opts), nil
+}
+
+// New returns a Worker for a store that is already open, as client.New
+// does; "queuectl worker" shares its store with its scheduler. BusyTimeout
+// is ignored, and closing the Worker closes st.
+func New(st store.Store, opts Options) *Worker {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.QueueStrategy == "" {
+		opts.QueueStrategy = "ordered"
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 3 * time.Second
+	}
+	if opts.ShutdownTimeout <= 0 {
+		opts.ShutdownTimeout = 30 * time.Second
+	}
+	if opts.Log == nil {
+		opts.Log = io.Discard
+	}
+	opts.Queues = slices.Clone(opts.Queues)
+	for i := range opts.Queues {
+		opts.Queues[i].Weight = max(opts.Queues[i].Weight, 1)
+	}
+
+	return &Worker{
+		st:       st,
+		c:        client.New(st, client.Options{}),
+		opts:     opts,
+		handlers: map[string]HandlerFunc{},
+		sd:       newShutdown(),
+	}
+}
+
+// Close closes the database.
+func (w *Worker) Close() error {
+	return w.st.Close()
+}
+
+// Run starts the workers and blocks until they have all stopped: when
+// each reached Limit, was drained or stopped with "queuectl worker", or
+// after ctx is done. Then it shuts down like Shutdown(SIGTERM). If that
+// shutdown handed jobs back to the queue it returns an *InterruptedError;
+// jobs handed back by a stop message were asked for and don't count.
+func (w *Worker) Run(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		w.Shutdown(syscall.SIGTERM)
+	})
+	defer stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.work()
+		}()
+	}
+	wg.Wait()
+
+	if n := w.sd.released.Load(); n > 0 {
+		return &InterruptedError{Jobs: n}
+	}
+	return nil
+}
+
+// Shutdown stops claiming jobs and lets running ones finish: commands get
+// sig and handlers see their context cancelled. Jobs still running after
+// ShutdownTimeout are killed and handed back to the queue. Calling it
+// again has no effect.
+func (w *Worker) Shutdown(sig os.Signal) {
+	w.shutdownOnce.Do(func() {
+		w.sd.begin(sig)
+		go func() {
+			select {
+			case <-w.sd.force:
+			case <-time.After(w.opts.ShutdownTimeout):
+				w.logf("Shutdown timeout reached → killing running jobs\n")
+				w.sd.forceStop()
+			}
+		}()
+	})
+}
+
+// Kill stops right away: running commands are killed and their jobs
+// handed back to the queue. Handlers can't be killed and are still waited
+// for.
+func (w *Worker) Kill() {
+	w.Shutdown(syscall.SIGTERM)
+	w.sd.forceStop()
+}
+
+func (w *Worker) logf(format string, args ...any) {
+	fmt.Fprintf(w.opts.Log, format, args...)
+}
+
+// config returns the value of key for jobs in queue, or its default if the
+// database can't be read.
+func (w *Worker) config(queue, key string) client.ConfigValue {
+	cfg, _ := w.c.GetConfig(context.Background(), queue, key)
+	return cfg
+}
+
+// handlerTypes are the job types this worker has handlers for, sorted.
+func (w *Worker) handlerTypes() []string {
+	types := make([]string, 0, len(w.handlers))
+	for t := range w.handlers {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+	return types
+}
+
+func randomWorkerID() string {
+	charset := "abcdefghijklmnopqrstuvwxyz1234567890"
+	id := ""
+	for i := 0; i < 8; i++ {
+		id += string(charset[rand.Intn(len(charset))])
+	}
+	return id
+}
+
+// work registers one worker and runs jobs until it stops.
+func (w *Worker) work() {
+	st, sd := w.st, w.sd
+	verbose := w.opts.Verbose
+
+	time.Sleep(time.Duration(rand.Intn(200)) * time.Millisecond)
+
+	workerId := randomWorkerID()
+	host, _ := os.Hostname()
+
+	err := st.RegisterWorker(store.Worker{Id: workerId, Queues: formatQueues(w.opts.Queues), Host: host, Pid: os.Getpid()})
+	if err != nil {
+		w.logf("Error registering worker: %v\n", err)
+		return
+	}
+
+	w.logf("Worker started: %s\n", workerId)
+
+	defer func() {
+		st.UnregisterWorker(workerId)
+		w.logf("Worker stopped: %s\n", workerId)
+	}()
+
+	// Load configurable values. Settings that can differ per queue are
+	// resolved for each job instead.
+	lease := time.Duration(w.config("", "lease-timeout").Int()) * time.Second
+	priorityAging := w.config("", "priority-aging").Int()
+	types := w.handlerTypes()
+
+	stopHeartbeat := make(chan struct{})
+	go heartbeat(st, workerId, lease, stopHeartbeat)
+	defer close(stopHeartbeat)
+
+	// This worker's own shutdown, so a stop message can end just this one
+	wsd, releaseWsd := sd.child()
+	defer releaseWsd()
+	ctl := &workerControl{}
+	go w.watchControl(workerId, ctl, wsd, stopHeartbeat)
+
+	jobCount := 0
+	var lastReap time.Time
+
+	for {
+
+		if ctl.draining.Load() {
+			w.logf("Drain requested → Worker exiting: %s\n", workerId)
+			return
+		}
+
+		if wsd.stopping() {
+			if !sd.stopping() {
+				w.logf("Stop requested → Worker exiting: %s (%d job(s) back in the queue)\n", workerId, wsd.released.Load())
+			}
+			return
+		}
+
+		// Recover jobs orphaned by crashed workers
+		if time.Since(lastReap) >= lease/3 {
+			lastReap = time.Now()
+			jobsReaped, workersReaped, err := st.ReapExpiredLeases(lease)
+			if err != nil {
+				w.logf("[%s] Error reaping expired leases: %v\n", workerId, err)
+			} else if verbose && (jobsReaped > 0 || workersReaped > 0) {
+				w.logf("[%s] 🧹 Recovered %d job(s) with expired leases, removed %d stale worker(s)\n",
+					workerId, jobsReaped, workersReaped)
+			}
+		}
+
+		if ctl.paused.Load() {
+			select {
+			case <-wsd.done:
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		if w.opts.Limit > 0 && jobCount >= w.opts.Limit {
+			return
+		}
+
+		// Claim a pending job ready for execution, trying the subscribed
+		// queues in this poll's order
+		var job *store.ClaimedJob
+		for _, queue := range queuePollOrder(w.opts.Queues, w.opts.QueueStrategy) {
+			job, err = st.Claim(store.ClaimRequest{WorkerId: workerId, Queue: queue, Types: types, Lease: lease, PriorityAging: priorityAging})
+			if err != store.ErrNoJob {
+				break
+			}
+		}
+		if err != nil {
+			if err != store.ErrNoJob {
+				w.logf("[%s] Error claiming job: %v\n", workerId, err)
+			} else if verbose {
+				// Debug: Check if there are any pending jobs at all
+				now := nowTime()
+				pendingCount, _ := st.CountJobs(store.ListFilter{State: "pending"})
+
+				if pendingCount > 0 {
+					// There are pending jobs but not ready yet
+					var nextJob, nextRunAt string
+					next, _, _ := st.ListJobs(store.ListFilter{State: "pending", Sort: "next_run_at", Limit: 1})
+					if len(next) > 0 {
+						nextJob, nextRunAt = next[0].Id, next[0].NextRunAt.String
+					}
+					w.logf("[%s] 💤 %d pending job(s). Next job %s scheduled for: %s (Current: %s)\n",
+						workerId, pendingCount, nextJob, nextRunAt, now)
+				} else {
+					w.logf("[%s] 💤 No jobs available, sleeping for %v...\n", workerId, w.opts.PollInterval)
+				}
+			}
+			select {
+			case <-wsd.done:
+			case <-time.After(w.opts.PollInterval):
+			}
+			continue
+		}
+
+		Id, Command, Attempts, MaxRetries := job.Id, job.Command, job.Attempts, job.MaxRetries
+
+		// Typed jobs run in-process when this worker has their handler;
+		// everything else runs its command in the shell
+		handler := w.handlers[job.Type.String]
+		if !job.Type.Valid {
+			handler = nil
+		}
+		task := "Command: " + Command
+		if handler != nil {
+			task = "Type: " + job.Type.String
+		}
+
+		if verbose {
+			if Attempts == 0 {
+				w.logf("[%s] 🆕 Picked up NEW job: %s (Queue: %s, Priority: %d, %s)\n", workerId, Id, job.Queue, job.Priority, task)
+			} else {
+				w.logf("[%s] 🔄 RETRYING job: %s (Attempt %d, %s)\n", workerId, Id, Attempts+1, task)
+			}
+			w.logf("[%s] ⚙️  Job %s state: processing\n", workerId, Id)
+		}
+
+		// A timeout set on the job wins over the queue and global defaults
+		timeout := time.Duration(w.config(job.Queue, "job-timeout").Int()) * time.Second
+		if job.TimeoutSeconds.Valid {
+			timeout = time.Duration(job.TimeoutSeconds.Int64) * time.Second
+		}
+
+		outputLimit := w.config(job.Queue, "output-limit").Int()
+		stdout := newTailBuffer(outputLimit)
+		stderr := newTailBuffer(outputLimit)
+
+		attemptId, attemptNum, err := st.StartAttempt(Id, workerId)
+		if err != nil {
+			w.logf("[%s] Error recording attempt for job %s: %v\n", workerId, Id, err)
+		}
+
+		// Every event of this run carries its attempt number and worker
+		event := func(name, from, to, detail string) store.Event {
+			return store.Event{Event: name, FromState: from, ToState: to, Attempt: attemptNum, WorkerId: workerId, Detail: detail}
+		}
+		// updated reports a failed state change; each one is guarded by the
+		// claim token and recorded in the job's history
+		updated := func(_ bool, err error) {
+			if err != nil {
+				w.logf("[%s] Error updating job %s: %v\n", workerId, Id, err)
+			}
+		}
+
+		st.RecordEvent(Id, event("claimed", "pending", "processing", "queue "+job.Queue))
+
+		stopStream := make(chan struct{})
+		if err == nil {
+			go streamAttemptOutput(st, attemptId, stdout, stderr, stopStream)
+		}
+
+		var payload json.RawMessage
+		if job.Payload.Valid {
+			payload = json.RawMessage(job.Payload.String)
+		}
+
+		// Give the job its own shutdown so "queuectl cancel" can kill it
+		jsd, releaseJsd := wsd.child()
+		running := &runningJob{Id: Id, sd: jsd}
+		ctl.job.Store(running)
+
+		var result execResult
+		if handler != nil {
+			result = runHandler(handler, job.Type.String, payload, timeout, stderr, jsd)
+		} else {
+			var env []string
+			if job.Env.Valid {
+				var envMap map[string]string
+				if err := json.Unmarshal([]byte(job.Env.String), &envMap); err != nil {
+					w.logf("[%s] Ignoring malformed env for job %s: %v\n", workerId, Id, err)
+				}
+				for key, value := range envMap {
+					env = append(env, key+"="+value)
+				}
+			}
+			if payload != nil {
+				env = append(env, "QUEUECTL_PAYLOAD="+string(payload))
+			}
+			result = runCommand(Command, env, timeout, stdout, stderr, jsd)
+		}
+		close(stopStream)
+		ctl.job.Store(nil)
+		releaseJsd()
+
+		if attemptId > 0 {
+			if err := finishAttempt(st, attemptId, result, stdout, stderr); err != nil {
+				w.logf("[%s] Error recording attempt for job %s: %v\n", workerId, Id, err)
+			} else if verbose {
+				w.logf("[%s] 📄 Job %s attempt %d exited with code %d (see: queuectl logs %s)\n",
+					workerId, Id, attemptNum, result.ExitCode, Id)
+			}
+		}
+
+		timedOut, err := result.TimedOut, result.Err
+
+		if err != nil && running.cancelled.Load() {
+			// STATE: processing → cancelled
+			updated(st.Cancelled(job, event("cancelled", "processing", "cancelled", describeResult(result, timeout))))
+
+			if verbose {
+				w.logf("[%s] 🚫 Job %s state: cancelled\n", workerId, Id)
+			}
+			continue
+		}
+
+		if err != nil && result.Interrupted {
+			// Stopped by a shutdown, not by its own failure: hand it back
+			// untouched so another worker can run it
+			// STATE: processing → pending
+			detail := "worker shut down before the job finished"
+			if sd.stopping() {
+				sd.released.Add(1)
+			} else {
+				// Only this worker got a stop message
+				wsd.released.Add(1)
+				detail = "worker was stopped before the job finished"
+			}
+			updated(st.Requeue(job, store.Requeue{
+				Reason:    "interrupted",
+				NextRunAt: nowTime(),
+				Event:     event("interrupted", "processing", "pending", detail),
+			}))
+
+			w.logf("[%s] ↩️  Job %s interrupted by shutdown → back to pending\n", workerId, Id)
+			continue
+		}
+
+		if err != nil {
+			policy := w.retryPolicy(job)
+
+			if policy.reschedules(result) {
+				// The job asked to run again later; this attempt doesn't count
+				// STATE: processing → pending
+				backoff := w.backoffPolicy(job).Delay(Attempts+1, time.Duration(job.LastBackoffSeconds.Int64)*time.Second)
+				nextRunStr := formatTime(time.Now().Add(backoff))
+
+				updated(st.Requeue(job, store.Requeue{
+					Reason:         "rescheduled",
+					NextRunAt:      nextRunStr,
+					BackoffSeconds: sql.NullInt64{Int64: int64(backoff / time.Second), Valid: true},
+					Event: event("rescheduled", "processing", "pending",
+						fmt.Sprintf("%s, next attempt at %s; attempt not counted", describeResult(result, timeout), nextRunStr)),
+				}))
+
+				if verbose {
+					w.logf("[%s] 🔁 Job %s exited with %d → rescheduled in %v (at %s), attempt not counted\n",
+						workerId, Id, result.ExitCode, backoff, nextRunStr)
+				}
+				continue
+			}
+
+			// Job FAILED
+			Attempts++
+
+			failureReason := "failed"
+			if timedOut {
+				failureReason = "timed_out"
+			}
+			retryable := policy.retryable(result)
+			if !retryable {
+				failureReason = fmt.Sprintf("non_retryable (exit code %d)", result.ExitCode)
+			}
+
+			if verbose {
+				if timedOut {
+					w.logf("[%s] ⌛ Job %s TIMED OUT after %v (Attempt %d/%d)\n", workerId, Id, timeout, Attempts, MaxRetries)
+				} else {
+					w.logf("[%s] ❌ Job %s FAILED (Attempt %d/%d): %v\n", workerId, Id, Attempts, MaxRetries, err)
+				}
+			}
+
+			if !retryable || Attempts >= MaxRetries {
+				// STATE: processing → dead (not retryable or max retries exceeded)
+				detail := fmt.Sprintf("%s; out of retries (%d/%d)", describeResult(result, timeout), Attempts, MaxRetries)
+				if !retryable {
+					detail = fmt.Sprintf("%s; not retried", describeResult(result, timeout))
+				}
+				updated(st.Fail(job, store.Failure{
+					Attempts: Attempts,
+					Reason:   failureReason,
+					Dead:     true,
+					Event:    event("dead", "processing", "dead", detail),
+				}))
+
+				if verbose {
+					if !retryable {
+						w.logf("[%s] ☠️  Job %s state: DEAD (exit code %d is not retried)\n", workerId, Id, result.ExitCode)
+					} else {
+						w.logf("[%s] ☠️  Job %s state: DEAD (exceeded max retries: %d)\n", workerId, Id, MaxRetries)
+					}
+				}
+
+			} else {
+				// STATE: processing → failed (will retry) → pending
+				backoff := w.backoffPolicy(job).Delay(Attempts, time.Duration(job.LastBackoffSeconds.Int64)*time.Second)
+				nextRun := time.Now().Add(backoff)
+				nextRunStr := formatTime(nextRun)
+
+				updated(st.Fail(job, store.Failure{
+					Attempts:       Attempts,
+					Reason:         failureReason,
+					NextRunAt:      nextRunStr,
+					BackoffSeconds: int64(backoff / time.Second),
+					Event:          event("failed", "processing", "failed", describeResult(result, timeout)),
+					Retry:          event("retry_scheduled", "failed", "pending", fmt.Sprintf("retry in %v, at %s", backoff, nextRunStr)),
+				}))
+
+				if verbose {
+					w.logf("[%s] ⏳ Job %s state: failed → Will retry in %v (at %s)\n", workerId, Id, backoff, nextRunStr)
+					w.logf("[%s] ⏰ Current time: %s, Next retry: %s\n", workerId, nowTime(), nextRunStr)
+					w.logf("[%s] 📝 Job %s state: pending (scheduled for retry)\n", workerId, Id)
+				}
+			}
+
+			continue
+		}
+
+		// Job SUCCEEDED
+		// STATE: processing → completed
+		updated(st.Complete(job, event("completed", "processing", "completed", describeResult(result, timeout))))
+
+		if verbose {
+			w.logf("[%s] ✅ Job %s state: completed\n", workerId, Id)
+		}
+
+		jobCount++
+	}
+}
+
+// Timestamps are stored as UTC RFC3339 text so that SQLite can compare
+// them as plain strings regardless of the local timezone.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func nowTime() string {
+	return formatTime(time.Now())
+}
@@ -9,6 +9,7 @@ Write-Host "`nCleaning old data..." -ForegroundColor Yellow
 if (Test-Path "data") {
     Remove-Item -Recurse -Force "data"
 }
+go run main.go db init
 
 # Test 1: Configuration
 Write-Host "`n[TEST 1] Configuration Management" -ForegroundColor Green
@@ -50,9 +51,8 @@ go run main.go status
 
 # Test 7: Retry from DLQ
 Write-Host "`n[TEST 7] Retry Dead Job" -ForegroundColor Green
-$dlqOutput = go run main.go dlq list | Select-String "ID:"
-if ($dlqOutput) {
-    $deadJob = ($dlqOutput[0] -split '\s+')[1]
+$deadJob = go run main.go dlq list --template '{{.Id}}' | Select-Object -First 1
+if ($deadJob) {
     Write-Host "Retrying job: $deadJob" -ForegroundColor Yellow
     go run main.go dlq retry $deadJob
     go run main.go list -s pending
@@ -1,6 +1,16 @@
 #!/bin/bash
 set -e
 
+# --postgres fails the run when PostgreSQL can't be tested, instead of
+# skipping those checks
+REQUIRE_POSTGRES=""
+for ARG in "$@"; do
+    case "$ARG" in
+        --postgres) REQUIRE_POSTGRES=1 ;;
+        *) echo "usage: $0 [--postgres]"; exit 2 ;;
+    esac
+done
+
 echo "======================================"
 echo "Testing queuectl - Job Queue System"
 echo "======================================"
@@ -9,6 +19,7 @@ echo "======================================"
 echo "
 🧹 Cleaning old data..."
 rm -rf data/
+go run main.go db init
 
 # Test 1: Configuration
 echo "
@@ -52,13 +63,652 @@ go run main.go status
 # Test 7: Retry from DLQ
 echo "
 ✅ Test 7: Retry Dead Job"
-DEAD_JOB=$(go run main.go dlq list | grep "ID:" | head -1 | awk '{print $2}')
+DEAD_JOB=$(go run main.go dlq list --template '{{.Id}}' | head -1)
 if [ ! -z "$DEAD_JOB" ]; then
     echo "Retrying job: $DEAD_JOB"
     go run main.go dlq retry $DEAD_JOB
     go run main.go list -s pending
 fi
 
+# Test 8: Concurrent claiming
+echo "
+✅ Test 8: Concurrent Workers Run Each Job Exactly Once"
+rm -rf data/
+go run main.go db init
+RUNS_LOG=$(mktemp)
+for i in $(seq 1 50); do
+    go run main.go enqueue -c "echo $i >> $RUNS_LOG" > /dev/null
+done
+timeout 15 go run main.go worker -c 8 -s 1 > /dev/null &
+timeout 15 go run main.go worker -c 8 -s 1 > /dev/null &
+wait || true
+
+TOTAL_RUNS=$(wc -l < "$RUNS_LOG")
+UNIQUE_RUNS=$(sort -u "$RUNS_LOG" | wc -l)
+rm -f "$RUNS_LOG"
+echo "Runs: $TOTAL_RUNS, unique jobs: $UNIQUE_RUNS"
+if [ "$TOTAL_RUNS" -ne 50 ] || [ "$UNIQUE_RUNS" -ne 50 ]; then
+    echo "❌ Expected every one of the 50 jobs to run exactly once"
+    exit 1
+fi
+
+# Test 9: Schema upgrade
+echo "
+✅ Test 9: Upgrade a Database Created Before Versioned Migrations"
+# testdata/v0.db was written by the queuectl from before versioned
+# migrations, in Asia/Kolkata (UTC+05:30): jobs with 9 columns and local
+# timestamps, except Next_run_at set at enqueue, which is UTC
+UPGRADE_DB="$(mktemp -d)/queue.db"
+cp testdata/v0.db "$UPGRADE_DB"
+upgraded() {
+    TZ=Asia/Kolkata go run main.go --db "$UPGRADE_DB" "$@"
+}
+if ! upgraded list | grep -q "db migrate"; then
+    echo "❌ Expected list to refuse a database that wasn't migrated"
+    exit 1
+fi
+upgraded db migrate --to 1
+upgraded db migrate
+upgraded db status
+
+VERSION=$(upgraded db status --template '{{.Version}}')
+JOBS=$(upgraded list --template '{{.Id}} {{.State}} {{.Queue}} {{.Priority}} {{.Attempts}}/{{.Max_retries}} {{.Created_at}} {{.Updated_at}} {{.Next_run_at}}')
+echo "Schema version: $VERSION"
+echo "$JOBS"
+EXPECTED_JOBS="5h8oi1oq completed default 0 0/2 2026-10-18T13:40:45Z 2026-10-18T13:40:45Z 2026-10-18T13:40:45Z
+shrard9f dead default 0 2/2 2026-10-18T13:40:45Z 2026-10-18T13:40:47Z 2026-10-18T13:40:47Z
+415n0v1g pending default 0 1/2 2026-10-18T13:40:55Z 2026-10-18T13:40:55Z 2026-10-18T13:49:15Z
+n3fpkite pending default 0 0/2 2026-10-18T13:40:59Z 2026-10-18T13:40:59Z 2026-10-18T13:40:59Z"
+if [ "$VERSION" -ne 4 ] || [ "$JOBS" != "$EXPECTED_JOBS" ]; then
+    echo "❌ Expected the upgrade to add Queue and Priority with their defaults, convert timestamps to UTC and fill in max-retries 2 from config"
+    exit 1
+fi
+WORKER_STATES=$(upgraded status --template '{{range .Workers}}{{.Worker_id}} {{.State}} {{end}}')
+if [ "$WORKER_STATES" != "algwp81q running zunh90id running " ]; then
+    echo "❌ Expected the workers' new State column to default to running, got: $WORKER_STATES"
+    exit 1
+fi
+
+# An upgraded job runs like any other
+timeout 30 go run main.go --db "$UPGRADE_DB" worker -s 1 -l 1 --no-scheduler > /dev/null
+STATE=$(upgraded list --template '{{.State}}' -c later)
+rm -rf "$(dirname "$UPGRADE_DB")"
+if [ "$STATE" != "completed" ]; then
+    echo "❌ Expected the upgraded pending job to run, it is $STATE"
+    exit 1
+fi
+
+# Test 10: Store conformance
+echo "
+✅ Test 10: Store Backends Pass the Same Conformance Checks"
+CONFORMANCE_DIR="$(mktemp -d)"
+
+# PostgreSQL is checked too: $QUEUECTL_TEST_POSTGRES, or a throwaway
+# cluster that stays up for the Go handler checks. Without either the
+# PostgreSQL checks are skipped, unless --postgres or the variable makes
+# them required.
+PG_DIR=""
+PG_TEST_DB=""
+PG_BIN=""
+if [ -z "$QUEUECTL_TEST_POSTGRES" ]; then
+    if command -v initdb > /dev/null && command -v pg_ctl > /dev/null; then
+        PG_BIN="$(dirname "$(command -v initdb)")"
+    elif command -v pg_config > /dev/null && [ -x "$(pg_config --bindir)/initdb" ]; then
+        PG_BIN="$(pg_config --bindir)"
+    else
+        for DIR in /usr/lib/postgresql/*/bin /usr/local/pgsql/bin; do
+            [ -x "$DIR/initdb" ] && PG_BIN="$DIR"
+        done
+    fi
+    if [ -z "$PG_BIN" ] && [ -n "$REQUIRE_POSTGRES" ]; then
+        echo "❌ PostgreSQL not found: install it (initdb and pg_ctl) or set QUEUECTL_TEST_POSTGRES to a database URL"
+        exit 1
+    elif [ -z "$PG_BIN" ]; then
+        echo "⏭️  PostgreSQL not found, skipping its checks; install it or set QUEUECTL_TEST_POSTGRES to include them"
+    fi
+fi
+
+# PostgreSQL refuses to run as root, so a root run hands the cluster to nobody
+pg() {
+    local CMD="$PG_BIN/$1"
+    shift
+    if [ "$(id -u)" -eq 0 ]; then
+        runuser -u nobody -- "$CMD" "$@"
+    else
+        "$CMD" "$@"
+    fi
+}
+
+stop_postgres() {
+    if [ -n "$PG_DIR" ]; then
+        pg pg_ctl -D "$PG_DIR/data" -m fast -w stop > /dev/null || true
+        rm -rf "$PG_DIR"
+    fi
+}
+trap stop_postgres EXIT
+
+if [ -n "$QUEUECTL_TEST_POSTGRES" ]; then
+    PG_TEST_DB="$QUEUECTL_TEST_POSTGRES"
+elif [ -n "$PG_BIN" ]; then
+    PG_DIR="$(mktemp -d)"
+    chmod 755 "$PG_DIR"
+    if [ "$(id -u)" -eq 0 ]; then
+        chown nobody "$PG_DIR"
+    fi
+    pg initdb -D "$PG_DIR/data" -U queuectl --auth=trust > /dev/null
+    pg pg_ctl -D "$PG_DIR/data" -l "$PG_DIR/log" -o "-k $PG_DIR -p 54329 -h ''" -w start > /dev/null
+    PG_TEST_DB="postgres://queuectl@/postgres?host=$PG_DIR&port=54329"
+fi
+
+CONFORMANCE_STATUS=0
+go run ./internal/store/conformance "$CONFORMANCE_DIR/queue.db" ${PG_TEST_DB:+"$PG_TEST_DB"} || CONFORMANCE_STATUS=$?
+rm -rf "$CONFORMANCE_DIR"
+if [ "$CONFORMANCE_STATUS" -ne 0 ]; then
+    echo "❌ Expected every backend to pass the conformance checks"
+    exit 1
+fi
+
+# The remaining tests signal and kill workers, so they run a built binary
+# (go run would hide the worker's pid), each against a fresh database
+TEST_DIR="$(mktemp -d)"
+QUEUECTL="$TEST_DIR/queuectl"
+go build -o "$QUEUECTL" .
+TEST_DB=""
+
+q() {
+    "$QUEUECTL" --db "$TEST_DB" "$@"
+}
+
+new_db() {
+    TEST_DB="$(mktemp -d -p "$TEST_DIR")/queue.db"
+    q db init > /dev/null
+}
+
+fail() {
+    echo "❌ $*"
+    exit 1
+}
+
+# Enqueue a job and print its id
+enqueue_id() {
+    q enqueue "$@" | sed -n 's/.*with ID: //p'
+}
+
+job_state() {
+    q show "$1" | sed -n 's/^State: *//p'
+}
+
+# Run a worker in the background; its pid is in $WORKER
+start_worker() {
+    "$QUEUECTL" --db "$TEST_DB" worker -s 1 --no-scheduler "$@" > /dev/null &
+    WORKER=$!
+}
+
+stop_worker() {
+    kill -TERM $WORKER
+    wait $WORKER || true
+}
+
+# Wait up to $3 seconds for job $1 to reach state $2
+wait_for_state() {
+    for _ in $(seq 1 $(( $3 * 5 ))); do
+        if [ "$(job_state "$1")" = "$2" ]; then
+            return 0
+        fi
+        sleep 0.2
+    done
+    fail "Expected job $1 to become $2, it is $(job_state "$1")"
+}
+
+# Test 11: Lease expiry
+echo "
+✅ Test 11: Jobs of a Killed Worker Are Reclaimed When Their Lease Expires"
+new_db
+q config set lease-timeout 2 > /dev/null
+RUNS_LOG="$TEST_DIR/lease-runs.log"
+JOB=$(enqueue_id -c "echo run >> $RUNS_LOG; sleep 3")
+"$QUEUECTL" --db "$TEST_DB" worker -s 1 --no-scheduler > /dev/null &
+WORKER=$!
+wait_for_state "$JOB" processing 10
+{ kill -9 $WORKER && wait $WORKER; } 2> /dev/null || true
+timeout 30 "$QUEUECTL" --db "$TEST_DB" worker -s 1 -l 1 --no-scheduler > /dev/null
+SHOW=$(q show "$JOB")
+echo "$SHOW"
+[ "$(job_state "$JOB")" = "completed" ] || fail "Expected the reclaimed job to complete"
+[ "$(wc -l < "$RUNS_LOG")" -eq 2 ] || fail "Expected the job to run again after its worker was killed"
+echo "$SHOW" | grep -q "#1 .*worker lost" || fail "Expected attempt 1 to be recorded as lost"
+echo "$SHOW" | grep -q "lease_expired" || fail "Expected the lease expiry in the job's history"
+
+# Test 12: Timeouts
+echo "
+✅ Test 12: A Timed Out Job Is Killed With Its Whole Process Group"
+new_db
+JOB=$(enqueue_id -c "sleep 987 | cat" --timeout 2s --max-retries 1)
+start_worker
+wait_for_state "$JOB" dead 15
+stop_worker
+q show "$JOB"
+q show "$JOB" | grep -q "^Failure Reason: *timed_out" || fail "Expected failure reason timed_out"
+if pgrep -f "sleep 987" > /dev/null; then
+    pkill -f "sleep 987"
+    fail "Expected the timeout to kill the job's sleep too"
+fi
+
+# Test 13: Attempt logs
+echo "
+✅ Test 13: Every Attempt's Output and Exit Code Are Kept"
+new_db
+q config set backoff-base 1 > /dev/null
+JOB=$(enqueue_id -c 'echo "to stdout"; echo "to stderr" >&2; exit 3' --max-retries 2)
+start_worker
+wait_for_state "$JOB" dead 15
+stop_worker
+LOGS=$(q logs "$JOB")
+echo "$LOGS"
+[ "$(echo "$LOGS" | grep -c "^Exit code: 3$")" -eq 2 ] || fail "Expected both attempts to record exit code 3"
+echo "$LOGS" | grep -A1 "^\[stdout\]" | grep -q "^to stdout$" || fail "Expected the attempt's stdout"
+echo "$LOGS" | grep -A1 "^\[stderr\]" | grep -q "^to stderr$" || fail "Expected the attempt's stderr"
+ATTEMPT_2=$(q logs "$JOB" --attempt 2)
+if ! echo "$ATTEMPT_2" | grep -q "Attempt 2 " || echo "$ATTEMPT_2" | grep -q "Attempt 1 "; then
+    fail "Expected logs --attempt 2 to show only the second attempt"
+fi
+q show "$JOB" | grep -q "^#2 .*exit code 3" || fail "Expected show to list attempt 2 with its exit code"
+
+# Test 14: Malformed job files
+echo "
+✅ Test 14: Malformed Job Files Are Rejected"
+new_db
+printf 'command: "echo never\n  - [\n' > "$TEST_DIR/bad.yaml"
+printf '[{"command": "echo never"}, {"comm' > "$TEST_DIR/bad.json"
+for SPEC_FILE in "$TEST_DIR/bad.yaml" "$TEST_DIR/bad.json"; do
+    if OUT=$(q enqueue -f "$SPEC_FILE" 2>&1); then
+        fail "Expected enqueue -f $(basename "$SPEC_FILE") to exit non-zero"
+    fi
+    echo "$OUT"
+    echo "$OUT" | grep -q "Error reading jobs" || fail "Expected an error message for $(basename "$SPEC_FILE")"
+done
+[ "$(q list --count --template '{{.Count}}')" -eq 0 ] || fail "Expected no jobs from malformed files"
+
+# Test 15: Max retries
+echo "
+✅ Test 15: Jobs Keep the Max Retries They Were Enqueued With"
+new_db
+q config set backoff-base 1 > /dev/null
+q config set max-retries 2 > /dev/null
+FROM_CONFIG=$(enqueue_id -c "exit 1")
+q config set max-retries 5 > /dev/null
+FROM_FLAG=$(enqueue_id -c "exit 1" --max-retries 3)
+start_worker
+wait_for_state "$FROM_CONFIG" dead 20
+wait_for_state "$FROM_FLAG" dead 20
+stop_worker
+q list
+q show "$FROM_CONFIG" | grep -q "^Attempts: *2 / 2$" || fail "Expected the job to keep max-retries 2 from config"
+q show "$FROM_FLAG" | grep -q "^Attempts: *3 / 3$" || fail "Expected --max-retries 3 to win over config"
+
+# Test 16: Delayed and scheduled jobs
+echo "
+✅ Test 16: Delayed and Scheduled Jobs Wait Until They Are Due"
+new_db
+DELAYED=$(enqueue_id -c "echo delayed" --delay 5s)
+LATER=$(enqueue_id -c "echo later" --run-at "$(date -u -d '+1 hour' +%Y-%m-%dT%H:%M:%SZ)")
+OVERDUE=$(enqueue_id -c "echo overdue" --run-at "2020-01-01T00:00:00+02:00")
+q list --template '{{.Id}} {{.Created_at}} {{.Next_run_at}}'
+DELAYED_CREATED=$(q list --template '{{.Created_at}}' -c "echo delayed")
+DELAYED_DUE=$(q list --template '{{.Next_run_at}}' -c "echo delayed")
+[ $(( $(date -d "$DELAYED_DUE" +%s) - $(date -d "$DELAYED_CREATED" +%s) )) -eq 5 ] || fail "Expected --delay 5s to be due 5s after it was created"
+[ "$(q list --template '{{.Next_run_at}}' -c "echo overdue")" = "2019-12-31T22:00:00Z" ] || fail "Expected --run-at to be stored in UTC"
+[ "$(q list -s scheduled --count --template '{{.Count}}')" -eq 2 ] || fail "Expected 2 scheduled jobs"
+start_worker
+wait_for_state "$OVERDUE" completed 10
+[ "$(job_state "$DELAYED")" = "pending" ] || fail "Expected the delayed job to wait"
+wait_for_state "$DELAYED" completed 15
+stop_worker
+DELAYED_STARTED=$(q show "$DELAYED" | sed -n 's/^#1 *worker [^ ]* *\([^ ]*\).*/\1/p')
+[ "$(date -d "$DELAYED_STARTED" +%s)" -ge "$(date -d "$DELAYED_DUE" +%s)" ] || fail "Expected the delayed job to start at $DELAYED_DUE or later, it started at $DELAYED_STARTED"
+[ "$(job_state "$LATER")" = "pending" ] || fail "Expected the job scheduled in an hour to still be pending"
+
+# Test 17: Recurring schedules
+echo "
+✅ Test 17: Two Schedulers Enqueue Each Occurrence Once (takes up to a minute)"
+new_db
+q schedule add --cron "* * * * *" -c "echo tick" > /dev/null
+"$QUEUECTL" --db "$TEST_DB" scheduler -i 1 > /dev/null &
+SCHEDULER_1=$!
+"$QUEUECTL" --db "$TEST_DB" scheduler -i 1 > /dev/null &
+SCHEDULER_2=$!
+for _ in $(seq 1 75); do
+    if [ "$(q list --count --template '{{.Count}}')" -gt 0 ]; then
+        break
+    fi
+    sleep 1
+done
+# Give the other scheduler a few more checks to enqueue it again
+sleep 3
+kill -TERM $SCHEDULER_1 $SCHEDULER_2
+wait $SCHEDULER_1 $SCHEDULER_2 || true
+OCCURRENCES=$(for JOB in $(q list --template '{{.Id}}'); do
+    q show "$JOB" | sed -n 's/.*enqueued .*occurrence \(.*\)/\1/p'
+done)
+echo "Occurrences enqueued: $OCCURRENCES"
+[ -n "$OCCURRENCES" ] || fail "Expected the schedule to enqueue a job within a minute"
+[ "$(echo "$OCCURRENCES" | wc -l)" -eq "$(echo "$OCCURRENCES" | sort -u | wc -l)" ] || fail "Expected each occurrence to be enqueued once"
+
+# Cron expressions are UTC whatever the local time zone, on the first run
+# and after a resume alike
+new_db
+TZ=Asia/Kolkata q schedule add --cron "0 3 * * *" -c "echo nightly" > /dev/null
+SCHEDULE=$(q schedule list | sed -n 's/^ID: //p')
+q schedule list | grep -q "^Next Run At: .*T03:00:00Z$" || fail "Expected the first run at 03:00 UTC"
+TZ=Asia/Kolkata q schedule pause "$SCHEDULE" > /dev/null
+TZ=Asia/Kolkata q schedule resume "$SCHEDULE" > /dev/null
+q schedule list | grep -q "^Next Run At: .*T03:00:00Z$" || fail "Expected the resumed schedule's next run at 03:00 UTC"
+
+# Test 18: Priorities
+echo "
+✅ Test 18: Higher Priority Jobs Run First"
+new_db
+RUNS_LOG="$TEST_DIR/priority-runs.log"
+for PRIORITY in 1 5 -2 0; do
+    enqueue_id -c "echo $PRIORITY >> $RUNS_LOG" -p $PRIORITY > /dev/null
+done
+enqueue_id -c "echo 5-later >> $RUNS_LOG" -p 5 > /dev/null
+start_worker
+wait_for_state "$(q list --template '{{.Id}}' -c "echo -2")" completed 15
+stop_worker
+ORDER=$(tr '\n' ' ' < "$RUNS_LOG")
+echo "Run order: $ORDER"
+[ "$ORDER" = "5 5-later 1 0 -2 " ] || fail "Expected jobs to run by priority, oldest first within a priority"
+
+# --priority fills in specs without one; an explicit 0 is kept
+JOB=$(enqueue_id --json '{"command": "echo explicit", "priority": 0}' -p 7)
+q show "$JOB" | grep -q "^Priority: *0$" || fail "Expected the spec's priority 0 to win over --priority"
+JOB=$(enqueue_id --json '{"command": "echo default"}' -p 7)
+q show "$JOB" | grep -q "^Priority: *7$" || fail "Expected --priority for a spec without one"
+
+# Test 19: Named queues
+echo "
+✅ Test 19: Workers Only Take Jobs From Their Queues"
+new_db
+RUNS_LOG="$TEST_DIR/queue-runs.log"
+EMAIL=$(enqueue_id -c "echo emails >> $RUNS_LOG" -q emails)
+REPORT=$(enqueue_id -c "echo reports >> $RUNS_LOG" -q reports)
+DEFAULT=$(enqueue_id -c "echo default >> $RUNS_LOG")
+[ "$(q list -q emails --template '{{.Id}}')" = "$EMAIL" ] || fail "Expected list -q emails to show only the emails job"
+start_worker -q emails
+wait_for_state "$EMAIL" completed 10
+sleep 2
+stop_worker
+[ "$(job_state "$REPORT")" = "pending" ] || fail "Expected a worker on emails to leave the reports job alone"
+[ "$(job_state "$DEFAULT")" = "pending" ] || fail "Expected a worker on emails to leave the default job alone"
+start_worker -q default,reports
+wait_for_state "$REPORT" completed 10
+stop_worker
+ORDER=$(tr '\n' ' ' < "$RUNS_LOG")
+echo "Run order: $ORDER"
+[ "$ORDER" = "emails default reports " ] || fail "Expected -q default,reports to take from default first"
+
+# Test 20: Per-queue config
+echo "
+✅ Test 20: Queue Config Overrides the Global Config"
+new_db
+q config set max-retries 2 > /dev/null
+q config set -q reports max-retries 4 > /dev/null
+q config set -q reports job-timeout 1 > /dev/null
+q config get -q reports -o csv | grep "max-retries"
+[ "$(q config get -q reports --template '{{if eq .Key "max-retries"}}{{.Value}} {{.Source}}{{end}}' | grep .)" = "4 queue" ] || fail "Expected config get -q reports to show max-retries 4 from the queue"
+[ "$(q config get -q emails --template '{{if eq .Key "max-retries"}}{{.Value}} {{.Source}}{{end}}' | grep .)" = "2 global" ] || fail "Expected other queues to use the global max-retries"
+REPORT=$(enqueue_id -c "sleep 3" -q reports)
+DEFAULT=$(enqueue_id -c "sleep 3")
+[ "$(q list -q reports --template '{{.Max_retries}}')" -eq 4 ] || fail "Expected the reports job to get max-retries 4"
+[ "$(q list -q default --template '{{.Max_retries}}')" -eq 2 ] || fail "Expected the default job to get max-retries 2"
+q config unset -q reports max-retries > /dev/null
+[ "$(q config get -q reports --template '{{if eq .Key "max-retries"}}{{.Source}}{{end}}' | grep .)" = "global" ] || fail "Expected config unset -q to fall back to the global value"
+start_worker -c 2
+wait_for_state "$DEFAULT" completed 15
+stop_worker
+q show "$REPORT" | grep -q "timed out after 1s" || fail "Expected the reports job to time out after the queue's job-timeout"
+
+# Test 21: Backoff cap
+echo "
+✅ Test 21: Retry Delays Stop Growing at backoff-max"
+new_db
+JOB=$(enqueue_id -c "exit 1" --max-retries 4 --backoff exponential --backoff-base 1s --backoff-max 2s)
+start_worker
+wait_for_state "$JOB" dead 20
+stop_worker
+DELAYS=$(q show "$JOB" | sed -n 's/.*retry_scheduled .*retry in \([^,]*\),.*/\1/p' | tr '\n' ' ')
+echo "Retry delays: $DELAYS"
+[ "$DELAYS" = "1s 2s 2s " ] || fail "Expected retry delays 1s 2s 2s"
+
+# Test 22: Exit code retry policy
+echo "
+✅ Test 22: Fail-Fast Codes Skip Retries and Reschedule Codes Keep Attempts"
+new_db
+q config set backoff-base 1 > /dev/null
+q config set reschedule-exit-code 75 > /dev/null
+MARKER="$TEST_DIR/rescheduled"
+FAIL_FAST=$(enqueue_id -c "exit 2" --fail-fast-on 2 --max-retries 3)
+RESCHEDULED=$(enqueue_id -c "if [ -e $MARKER ]; then exit 0; fi; touch $MARKER; exit 75" --max-retries 3)
+start_worker
+wait_for_state "$FAIL_FAST" dead 10
+wait_for_state "$RESCHEDULED" completed 15
+stop_worker
+q show "$FAIL_FAST" | grep -q "^Attempts: *1 / 3$" || fail "Expected the fail-fast job to be dead after one attempt"
+q show "$FAIL_FAST" | grep -q "^Failure Reason: *non_retryable (exit code 2)" || fail "Expected the fail-fast job to be non_retryable"
+[ "$(q show "$RESCHEDULED" | grep -c "^#[0-9]")" -eq 2 ] || fail "Expected the rescheduled job to run twice"
+q show "$RESCHEDULED" | grep -q "^Attempts: *0 / 3$" || fail "Expected the rescheduled run not to use an attempt"
+q show "$RESCHEDULED" | grep -q "attempt not counted" || fail "Expected the reschedule in the job's history"
+
+# Test 23: Graceful shutdown
+echo "
+✅ Test 23: SIGTERM Lets Running Jobs Finish Within --shutdown-timeout"
+new_db
+JOB=$(enqueue_id -c 'trap "echo cleaning up; exit 0" TERM; sleep 10 & wait')
+start_worker
+wait_for_state "$JOB" processing 10
+sleep 1
+WORKER_STATUS=0
+kill -TERM $WORKER
+wait $WORKER || WORKER_STATUS=$?
+[ "$WORKER_STATUS" -eq 0 ] || fail "Expected the worker to exit 0 once its job finished, got $WORKER_STATUS"
+[ "$(job_state "$JOB")" = "completed" ] || fail "Expected the job that handled SIGTERM to complete"
+q logs "$JOB" | grep -q "^cleaning up$" || fail "Expected the job's TERM trap to run"
+
+JOB=$(enqueue_id -c 'trap "" TERM; sleep 10')
+start_worker --shutdown-timeout 1s
+wait_for_state "$JOB" processing 10
+sleep 1
+WORKER_STATUS=0
+kill -TERM $WORKER
+wait $WORKER || WORKER_STATUS=$?
+[ "$WORKER_STATUS" -ne 0 ] || fail "Expected the worker to exit non-zero after killing an unfinished job"
+[ "$(job_state "$JOB")" = "pending" ] || fail "Expected the killed job to be back in pending"
+q show "$JOB" | grep -q "^Attempts: *0 / 3$" || fail "Expected the interrupted run not to use an attempt"
+
+# Test 24: Worker control messages
+echo "
+✅ Test 24: Workers Obey Pause, Resume, Drain and Stop"
+new_db
+
+# Print the id the worker started by start_worker registered with
+worker_id() {
+    for _ in $(seq 1 50); do
+        ID=$(q status --template "{{range .Workers}}{{if eq .Pid $WORKER}}{{.Worker_id}}{{end}}{{end}}")
+        if [ -n "$ID" ]; then
+            echo "$ID"
+            return 0
+        fi
+        sleep 0.2
+    done
+    fail "Expected worker $WORKER to register"
+}
+
+worker_state() {
+    q status --template "{{range .Workers}}{{if eq .Worker_id \"$1\"}}{{.State}}{{end}}{{end}}"
+}
+
+start_worker
+ID=$(worker_id)
+q worker pause "$ID"
+sleep 2
+[ "$(worker_state "$ID")" = "paused" ] || fail "Expected the worker to be paused"
+JOB=$(enqueue_id -c "echo after resume")
+sleep 3
+[ "$(job_state "$JOB")" = "pending" ] || fail "Expected a paused worker to leave the job pending"
+q worker resume "$ID"
+wait_for_state "$JOB" completed 10
+[ "$(worker_state "$ID")" = "running" ] || fail "Expected the resumed worker to be running"
+
+JOB=$(enqueue_id -c "sleep 3")
+wait_for_state "$JOB" processing 10
+q worker drain "$ID"
+WORKER_STATUS=0
+wait $WORKER || WORKER_STATUS=$?
+[ "$WORKER_STATUS" -eq 0 ] || fail "Expected a drained worker to exit 0, got $WORKER_STATUS"
+[ "$(job_state "$JOB")" = "completed" ] || fail "Expected the drained worker to finish its job"
+
+start_worker
+ID=$(worker_id)
+JOB=$(enqueue_id -c "sleep 30")
+wait_for_state "$JOB" processing 10
+q worker stop "$ID"
+WORKER_STATUS=0
+wait $WORKER || WORKER_STATUS=$?
+[ "$WORKER_STATUS" -eq 0 ] || fail "Expected a stopped worker to exit 0, got $WORKER_STATUS"
+[ "$(job_state "$JOB")" = "pending" ] || fail "Expected the stopped worker's job to be back in pending"
+q show "$JOB" | grep -q "interrupted .*worker was stopped" || fail "Expected the stop in the job's history"
+
+# Test 25: Cancelling a running job
+echo "
+✅ Test 25: Cancelling a Running Job Terminates It"
+new_db
+JOB=$(enqueue_id -c "sleep 876")
+start_worker
+wait_for_state "$JOB" processing 10
+q cancel "$JOB" -r "no longer needed"
+wait_for_state "$JOB" cancelled 10
+stop_worker
+SHOW=$(q show "$JOB")
+echo "$SHOW"
+if pgrep -f "^sleep 876" > /dev/null; then
+    pkill -f "^sleep 876"
+    fail "Expected cancel to terminate the running command"
+fi
+echo "$SHOW" | grep -q "cancel_requested" || fail "Expected the cancel request in the job's history"
+echo "$SHOW" | grep -q "cancelled .*processing → cancelled" || fail "Expected the cancellation in the job's history"
+
+# Test 26: Pausing queues
+echo "
+✅ Test 26: Paused Queues Wait Until They Are Resumed"
+new_db
+q queue pause emails --reason "SMTP outage"
+q queue pause reports --for 4s
+EMAIL=$(enqueue_id -c "echo email" -q emails)
+REPORT=$(enqueue_id -c "echo report" -q reports)
+OTHER=$(enqueue_id -c "echo other")
+q status | sed -n '/QUEUES/,/WORKERS/p'
+[ "$(q list -q emails --template '{{.Queue_paused}}')" = "true" ] || fail "Expected list to show the emails queue as paused"
+start_worker
+wait_for_state "$OTHER" completed 10
+[ "$(job_state "$EMAIL")" = "pending" ] || fail "Expected the job in the paused emails queue to wait"
+[ "$(job_state "$REPORT")" = "pending" ] || fail "Expected the job in the paused reports queue to wait"
+wait_for_state "$REPORT" completed 15
+[ "$(job_state "$EMAIL")" = "pending" ] || fail "Expected emails to stay paused without --for"
+q queue resume emails
+wait_for_state "$EMAIL" completed 10
+stop_worker
+
+# Test 27: Output formats
+echo "
+✅ Test 27: Lists Print as JSON, JSONL, YAML, CSV or a Template"
+new_db
+EMAIL=$(enqueue_id -c "echo email" -q emails)
+OTHER=$(enqueue_id -c "echo a,b")
+q list -o json | grep -q "^    \"id\": \"$EMAIL\",$" || fail "Expected list -o json to include $EMAIL"
+[ "$(q list -o json | grep -c '"id":')" -eq 2 ] || fail "Expected list -o json to print 2 jobs"
+[ "$(q list -o jsonl | grep -c '^{"id":')" -eq 2 ] || fail "Expected list -o jsonl to print one job per line"
+q list -o yaml | grep -q "^- id: $OTHER$" || fail "Expected list -o yaml to include $OTHER"
+CSV=$(q list -o csv)
+echo "$CSV"
+echo "$CSV" | head -1 | grep -q "^id,queue,command," || fail "Expected a csv header"
+echo "$CSV" | grep -q "^$OTHER,default,\"echo a,b\",,,pending," || fail "Expected csv to quote commas and leave empty fields empty"
+[ "$(q list --template '{{.Id}}:{{.Queue}}' | tr '\n' ' ')" = "$EMAIL:emails $OTHER:default " ] || fail "Expected --template to be applied to each job"
+q status -o json | grep -q '"queue": "emails"' || fail "Expected status -o json to include the emails queue"
+q config get -o jsonl | grep -q '^{"key":"max-retries","value":"3","source":"default"' || fail "Expected config get -o jsonl to print each key"
+if q list -o xml 2> /dev/null; then
+    fail "Expected an unknown --output to be rejected"
+fi
+
+# Test 28: List filters and paging
+echo "
+✅ Test 28: List Filters, Sorts and Pages Through Jobs"
+new_db
+for I in 1 2 3 4 5; do
+    enqueue_id -c "echo job$I" -p $(( I % 3 )) -t "t$(( I % 2 ))" > /dev/null
+done
+q cancel "$(q list -c job3 --template '{{.Id}}')" > /dev/null
+list_commands() {
+    q list --template '{{.Command}}' "$@" | sed 's/echo //' | tr '\n' ' '
+}
+[ "$(list_commands -t t1)" = "job1 job3 job5 " ] || fail "Expected -t t1 to match jobs 1, 3 and 5"
+[ "$(list_commands -m 'job[24]$')" = "job2 job4 " ] || fail "Expected -m to match jobs 2 and 4"
+[ "$(list_commands -s cancelled)" = "job3 " ] || fail "Expected -s cancelled to match job 3"
+[ "$(list_commands --sort priority:desc)" = "job5 job2 job4 job1 job3 " ] || fail "Expected --sort priority:desc to order by priority, newest first on ties"
+[ "$(list_commands --offset 3 -n 1 2> /dev/null)" = "job4 " ] || fail "Expected --offset 3 -n 1 to show job 4"
+[ "$(q list -t t1 -s pending --count --template '{{.Count}}')" -eq 2 ] || fail "Expected 2 pending jobs tagged t1"
+PAGES=""
+CURSOR=""
+for _ in 1 2 3 4; do
+    PAGE_ERR="$TEST_DIR/page.err"
+    PAGES+="$(list_commands -n 2 ${CURSOR:+--after "$CURSOR"} 2> "$PAGE_ERR")| "
+    CURSOR=$(sed -n 's/.*--after //p' "$PAGE_ERR")
+    if [ -z "$CURSOR" ]; then
+        break
+    fi
+done
+echo "Pages: $PAGES"
+[ "$PAGES" = "job1 job2 | job3 job4 | job5 | " ] || fail "Expected --limit 2 with --after to page through all 5 jobs"
+
+# --worker finds the jobs a worker ran after they are done, not only while
+# they run
+start_worker
+ID=$(worker_id)
+for I in 1 2 4 5; do
+    wait_for_state "$(q list -c "job$I" --template '{{.Id}}')" completed 15
+done
+stop_worker
+[ "$(list_commands -w "$ID")" = "job1 job2 job4 job5 " ] || fail "Expected -w to match the completed jobs the worker ran"
+[ -z "$(list_commands -w nobody)" ] || fail "Expected -w of another worker to match nothing"
+
+# Test 29: Job details
+echo "
+✅ Test 29: Show Prints a Job's Details, Attempts and History"
+new_db
+q config set backoff-base 1 > /dev/null
+MARKER="$TEST_DIR/retried"
+JOB=$(enqueue_id --json "{\"command\": \"if [ -e $MARKER ]; then echo \$GREETING; exit 0; fi; touch $MARKER; exit 4\", \"queue\": \"emails\", \"priority\": 2, \"env\": {\"GREETING\": \"hello\"}, \"tags\": [\"billing\"]}")
+start_worker
+wait_for_state "$JOB" completed 15
+stop_worker
+SHOW=$(q show "$JOB")
+echo "$SHOW"
+for FIELD in "Queue: *emails" "State: *completed" "Attempts: *1 / 3" "Priority: *2" "Tags: *billing" "Env: *GREETING=hello"; do
+    echo "$SHOW" | grep -q "^$FIELD$" || fail "Expected show to print $FIELD"
+done
+echo "$SHOW" | grep -q "^#1 .*exit code 4$" || fail "Expected attempt 1 with exit code 4"
+echo "$SHOW" | grep -q "^#2 .*exit code 0$" || fail "Expected attempt 2 with exit code 0"
+echo "$SHOW" | grep -q "^    \[stdout\] hello$" || fail "Expected attempt 2's output"
+EVENTS=$(echo "$SHOW" | sed -n '/^----- History -----$/,$p' | awk 'NR > 1 && $2 != "" {print $2}' | grep -v "^=" | tr '\n' ' ')
+[ "$EVENTS" = "enqueued claimed failed retry_scheduled claimed completed " ] || fail "Expected the job's history in order, got: $EVENTS"
+
+# Test 30: Go handlers
+echo "
+✅ Test 30: Go Handlers Complete, Retry, Recover From Panics and Fail Fast"
+go run ./pkg/worker/internal/handlercheck "$TEST_DIR/handlers.db" ${PG_TEST_DB:+"$PG_TEST_DB"} || fail "Expected every handler check to pass"
+
+rm -rf "$TEST_DIR"
+
 echo "
 ======================================"
 echo "✅ All Tests Completed Successfully"